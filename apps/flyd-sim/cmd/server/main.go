@@ -8,10 +8,17 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/devghori1264/aerophoenix/flyd-sim/internal/api"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/clock"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/correlation"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/events"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/heartbeat"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/inventory"
 	natsclient "github.com/devghori1264/aerophoenix/flyd-sim/internal/nats"
 	"github.com/devghori1264/aerophoenix/flyd-sim/internal/server"
 	"github.com/devghori1264/aerophoenix/flyd-sim/internal/storage"
@@ -24,6 +31,9 @@ import (
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
+// version is stamped at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
 func initTracer() (*sdktrace.TracerProvider, error) {
 	exp, err := stdout.New(stdout.WithPrettyPrint())
 	if err != nil {
@@ -40,8 +50,42 @@ func main() {
 	metricsAddr := flag.String("metrics-addr", ":9090", "Metrics listen address")
 	dbPath := flag.String("db", "./data/badger", "Badger DB path")
 	natsURL := flag.String("nats", "nats://nats:4222", "NATS URL")
+	kvMirror := flag.Bool("nats-kv", false, "Mirror machine state into the JetStream KV bucket \"machines\"")
+	flatSubject := flag.Bool("nats-flat-subject", false, "Also publish machine events on the legacy flat machines.events subject")
+	sinkNames := flag.String("sinks", "nats", "Comma-separated event sinks: nats, file, stdout, webhook")
+	sinkFile := flag.String("sink-file", "./data/events.ndjson", "NDJSON output path for the file sink")
+	webhookURL := flag.String("webhook-url", "", "Endpoint for the webhook sink")
+	webhookSecret := flag.String("webhook-secret", "", "HMAC secret used to sign webhook payloads")
+	webhookAttempts := flag.Int("webhook-attempts", 5, "Delivery attempts per webhook event before it is dropped")
+	chaosSeed := flag.Uint64("chaos-seed", chaos.DefaultSeed, "Seed for probabilistic chaos faults; reuse it to reproduce a run")
+	scenarioPath := flag.String("chaos-scenario", "", "YAML chaos scenario to start at boot")
+	crashInterval := flag.Duration("crash-check-interval", time.Second, "How often running machines are checked against crash faults (0 disables)")
+	inventoryPath := flag.String("inventory", "", "YAML region catalog and host inventory; regions outside it are rejected and machines are placed on its hosts")
+	queueOnFull := flag.Bool("queue-on-full", false, "Queue machines as pending when their region is full instead of rejecting them")
+	preemptGrace := flag.Duration("preemption-grace", server.DefaultPreemptionGrace, "How long preempted machines keep running after machine.preempted before they are stopped")
+	latencyOrigin := flag.String("latency-origin", "", "Region latency samples are measured from (defaults to each machine's own region)")
+	latencyEvery := flag.Duration("latency-sample-interval", 5*time.Second, "Interval between machine.latency samples (0 disables)")
+	hostID := flag.String("host-id", "", "Host identifier reported in heartbeats (defaults to the hostname)")
+	heartbeatEvery := flag.Duration("heartbeat-interval", 10*time.Second, "Interval between flyd.heartbeat events (0 disables)")
 	flag.Parse()
 
+	if *hostID == "" {
+		if hn, err := os.Hostname(); err == nil {
+			*hostID = hn
+		} else {
+			*hostID = "flyd-sim"
+		}
+	}
+
+	var sinkList []string
+	sinkSet := map[string]bool{}
+	for _, name := range strings.Split(*sinkNames, ",") {
+		if name = strings.TrimSpace(name); name != "" && !sinkSet[name] {
+			sinkSet[name] = true
+			sinkList = append(sinkList, name)
+		}
+	}
+
 	tp, err := initTracer()
 	if err != nil {
 		log.Printf("otel init error: %v", err)
@@ -56,25 +100,105 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to open badger store: %v", err)
 	}
-	defer store.Close()
 
-	pub, err := natsclient.NewPublisher(*natsURL)
-	if err != nil {
-		log.Printf("warning: nats not connected: %v", err)
+	var pub *natsclient.Publisher
+	if sinkSet["nats"] || *kvMirror {
+		var pubOpts []natsclient.Option
+		if *flatSubject {
+			pubOpts = append(pubOpts, natsclient.WithFlatSubject())
+		}
+		pub, err = natsclient.NewPublisher(*natsURL, pubOpts...)
+		if err != nil {
+			log.Printf("warning: nats not connected: %v", err)
+		}
 	}
-	defer func() {
-		if pub != nil {
-			pub.Close()
+	clk := clock.Real()
+	var sinks events.Fanout
+	pubIsSink := false
+	for _, name := range sinkList {
+		switch name {
+		case "nats":
+			if pub != nil {
+				sinks = append(sinks, pub)
+				pubIsSink = true
+			}
+		case "stdout":
+			sinks = append(sinks, events.NewStdoutSink(events.WithClock(clk)))
+		case "file":
+			fs, err := events.NewFileSink(*sinkFile, events.WithClock(clk))
+			if err != nil {
+				log.Fatalf("failed to open event file %s: %v", *sinkFile, err)
+			}
+			sinks = append(sinks, fs)
+		case "webhook":
+			if *webhookURL == "" {
+				log.Fatalf("webhook sink requires -webhook-url")
+			}
+			sinks = append(sinks, events.NewWebhookSink(*webhookURL, *webhookSecret, events.WithMaxAttempts(*webhookAttempts), events.WithWebhookClock(clk)))
+		default:
+			log.Fatalf("unknown event sink %q", name)
 		}
-	}()
+	}
+	var sink events.EventSink
+	switch len(sinks) {
+	case 0:
+		log.Printf("warning: no event sinks configured, events will be discarded")
+	case 1:
+		sink = sinks[0]
+	default:
+		sink = sinks
+	}
+
+	engine := chaos.NewEngine(chaos.WithSeed(*chaosSeed), chaos.WithClock(clk), chaos.WithNotifier(events.ChaosNotifier(sink, clk)))
+	if sink != nil {
+		sink = events.NewDeliveryChaosSink(sink, engine)
+	}
 
-	srv := server.New(store, pub)
+	if *kvMirror && pub != nil {
+		kvCtx, kvCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		kv, err := pub.KeyValue(kvCtx, natsclient.MachinesBucket)
+		if err != nil {
+			log.Printf("warning: kv mirror disabled: %v", err)
+		} else {
+			mirror := storage.NewKVMirror(store, kv, engine)
+			if err := mirror.Sync(kvCtx); err != nil {
+				log.Printf("warning: kv initial sync failed: %v", err)
+			}
+			store = mirror
+		}
+		kvCancel()
+	}
+
+	// Storage faults apply to the server only; heartbeats keep reading the
+	// real store.
+	srvOpts := []server.Option{server.WithChaos(engine), server.WithClock(clk), server.WithPreemptionGrace(*preemptGrace)}
+	if *inventoryPath != "" {
+		cfg, err := inventory.LoadConfig(*inventoryPath)
+		if err != nil {
+			log.Fatalf("failed to load inventory: %v", err)
+		}
+		inv, err := inventory.New(cfg)
+		if err != nil {
+			log.Fatalf("invalid inventory: %v", err)
+		}
+		srvOpts = append(srvOpts, server.WithInventory(inv))
+		if *queueOnFull {
+			srvOpts = append(srvOpts, server.WithCapacityQueue())
+		}
+	}
+	srv := server.New(storage.NewChaosStore(store, engine), sink, srvOpts...)
+	if err := srv.RestorePlacements(context.Background()); err != nil {
+		log.Fatalf("failed to restore placements: %v", err)
+	}
 
 	lis, err := net.Listen("tcp", *grpcAddr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		correlation.UnaryServerInterceptor(),
+		engine.UnaryServerInterceptor(srv.MachineRegion),
+	))
 	srv.RegisterGRPC(grpcServer)
 
 	go func() {
@@ -84,7 +208,17 @@ func main() {
 		}
 	}()
 
-	httpHandler := api.NewHTTPHandlerWithPublisher(srv, pub)
+	scenarios := chaos.NewScenarioRunner(engine)
+	if *scenarioPath != "" {
+		sc, err := chaos.LoadScenario(*scenarioPath)
+		if err != nil {
+			log.Fatalf("failed to load chaos scenario: %v", err)
+		}
+		run := scenarios.Start(sc)
+		log.Printf("chaos scenario %q started as %s", sc.Name, run.ID)
+	}
+
+	httpHandler := api.NewHTTPHandler(srv, engine, scenarios)
 	httpServer := &http.Server{Addr: *httpAddr, Handler: httpHandler}
 	go func() {
 		log.Printf("HTTP shim listening on %s", *httpAddr)
@@ -101,20 +235,43 @@ func main() {
 		}
 	}()
 
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	defer bgCancel()
+	go srv.RunCrashInjector(bgCtx, *crashInterval)
+	go srv.RunLatencySampler(bgCtx, *latencyOrigin, *latencyEvery)
+
+	emitter := heartbeat.NewEmitter(heartbeat.Config{
+		HostID:   *hostID,
+		Version:  version,
+		Interval: *heartbeatEvery,
+		Clock:    clk,
+	}, store, sink, engine)
+	go emitter.Run(bgCtx)
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
 	log.Printf("shutdown initiated")
 
+	bgCancel()
 	grpcServer.GracefulStop()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := httpServer.Shutdown(ctx); err != nil {
 		log.Printf("http server shutdown error: %v", err)
 	}
-	if pub != nil {
+	if sink != nil {
+		if err := sink.Close(); err != nil {
+			log.Printf("event sink close error: %v", err)
+		}
+	}
+	// The publisher is closed by the sink when it is one; otherwise it only
+	// backs the KV mirror and is closed here, before the store it mirrors.
+	if pub != nil && !pubIsSink {
 		pub.Close()
 	}
-	store.Close()
+	if err := store.Close(); err != nil {
+		log.Printf("store close error: %v", err)
+	}
 	log.Println("shutdown complete")
 }
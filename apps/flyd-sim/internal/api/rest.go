@@ -1,45 +1,91 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"log"
 	"net/http"
-	"sync"
 	"time"
 
-	natsclient "github.com/devghori1264/aerophoenix/flyd-sim/internal/nats"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/correlation"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/inventory"
 	proto "github.com/devghori1264/aerophoenix/flyd-sim/internal/proto"
 	"github.com/devghori1264/aerophoenix/flyd-sim/internal/server"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/storage"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// LeaseNonceHeader carries the nonce of a machine's lease on calls that
+// mutate the machine.
+const LeaseNonceHeader = "X-Lease-Nonce"
+
 type Handler struct {
 	srv       *server.Server
-	publisher *natsclient.Publisher
-
-	mu          sync.RWMutex
-	partitioned map[string]bool
-	latencyMs   map[string]int
+	chaos     *chaos.Engine
+	scenarios *chaos.ScenarioRunner
+	handler   http.Handler
 }
 
-func NewHTTPHandlerWithPublisher(srv *server.Server, p *natsclient.Publisher) http.Handler {
+// NewHTTPHandler returns the HTTP shim for srv. Machine events are emitted by
+// the server itself, so the handler does not publish anything. Chaos faults
+// are enforced by middleware backed by the engine shared with the server.
+func NewHTTPHandler(srv *server.Server, engine *chaos.Engine, scenarios *chaos.ScenarioRunner) *Handler {
 	h := &Handler{
-		srv:         srv,
-		publisher:   p,
-		partitioned: make(map[string]bool),
-		latencyMs:   make(map[string]int),
+		srv:       srv,
+		chaos:     engine,
+		scenarios: scenarios,
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ping", h.handlePing)
-	mux.HandleFunc("/create", h.handleCreate)
-	mux.HandleFunc("/get", h.handleGet)
-
+	h.route(mux, "/create", "CreateMachine", h.handleCreate)
+	h.route(mux, "/get", "GetMachine", h.handleGet)
+	h.route(mux, "GET /v1/machines/{id}/wait", "WaitMachine", h.handleWait)
+	h.route(mux, "POST /v1/machines/{id}/migrate", "MigrateMachine", h.handleMigrate)
+	h.route(mux, "DELETE /v1/machines/{id}", "DestroyMachine", h.handleAction(srv.DestroyMachine))
+	h.route(mux, "POST /v1/machines/{id}/start", "StartMachine", h.handleAction(srv.StartMachine))
+	h.route(mux, "POST /v1/machines/{id}/stop", "StopMachine", h.handleAction(srv.StopMachine))
+	h.route(mux, "POST /v1/machines/{id}/restart", "RestartMachine", h.handleAction(srv.RestartMachine))
+	h.route(mux, "POST /v1/machines/{id}/suspend", "SuspendMachine", h.handleAction(srv.SuspendMachine))
+	h.route(mux, "POST /v1/machines/{id}/resume", "ResumeMachine", h.handleAction(srv.ResumeMachine))
+	h.route(mux, "POST /v1/machines/{id}/lease", "AcquireLease", h.handleAcquireLease)
+	h.route(mux, "GET /v1/machines/{id}/lease", "GetLease", h.handleGetLease)
+	h.route(mux, "DELETE /v1/machines/{id}/lease", "ReleaseLease", h.handleReleaseLease)
+	mux.HandleFunc("GET /v1/regions", h.handleListRegions)
+	mux.HandleFunc("GET /v1/regions/rtt", h.handleRTTMatrix)
+	mux.HandleFunc("GET /v1/hosts", h.handleListHosts)
+	mux.HandleFunc("POST /v1/hosts/{id}/{action}", h.handleHostAction)
+
+	// Chaos control is never subject to chaos, so a fault can always be healed.
+	mux.HandleFunc("GET /chaos", h.handleChaosState)
 	mux.HandleFunc("/chaos/partition", h.handlePartition)
 	mux.HandleFunc("/chaos/heal", h.handleHeal)
 	mux.HandleFunc("/chaos/latency", h.handleLatency)
+	mux.HandleFunc("POST /chaos/faults", h.handleAddFault)
+	mux.HandleFunc("DELETE /chaos/faults/{id}", h.handleRemoveFault)
+	mux.HandleFunc("GET /chaos/scenarios", h.handleListScenarios)
+	mux.HandleFunc("POST /chaos/scenarios", h.handleStartScenario)
+	mux.HandleFunc("GET /chaos/scenarios/{id}", h.handleGetScenario)
+	mux.HandleFunc("DELETE /chaos/scenarios/{id}", h.handleStopScenario)
+
+	h.handler = correlation.Middleware(mux)
+	return h
+}
 
-	return mux
+// route registers a machine route behind the chaos middleware. rpc names the
+// equivalent gRPC method so method-scoped faults apply to both.
+func (h *Handler) route(mux *http.ServeMux, pattern, rpc string, fn http.HandlerFunc) {
+	mux.Handle(pattern, h.chaos.Middleware(h.srv.MachineRegion, rpc, fn))
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.ServeHTTP(w, r)
 }
 
 func (h *Handler) handlePing(w http.ResponseWriter, _ *http.Request) {
@@ -48,8 +94,23 @@ func (h *Handler) handlePing(w http.ResponseWriter, _ *http.Request) {
 
 func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Name   string `json:"name"`
-		Region string `json:"region"`
+		Name          string `json:"name"`
+		Region        string `json:"region"`
+		RestartPolicy *struct {
+			Policy     string `json:"policy"`
+			MaxRetries int32  `json:"max_retries"`
+		} `json:"restart_policy"`
+		Guest *struct {
+			CPUs     int32 `json:"cpus"`
+			MemoryMB int32 `json:"memory_mb"`
+		} `json:"guest"`
+		Labels      map[string]string `json:"labels"`
+		Constraints *struct {
+			Host         string            `json:"host"`
+			AntiAffinity map[string]string `json:"anti_affinity"`
+			SpreadBy     string            `json:"spread_by"`
+		} `json:"constraints"`
+		PriorityClass string `json:"priority_class"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid JSON payload")
@@ -60,40 +121,41 @@ func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if h.isPartitioned(req.Region) {
-		writeError(w, http.StatusServiceUnavailable, "region partitioned")
-		return
-	}
-
 	ctx := r.Context()
-	res, err := h.srv.CreateMachine(ctx, &proto.CreateRequest{
-		Name:   req.Name,
-		Region: req.Region,
-	})
+	createReq := &proto.CreateRequest{
+		Name:          req.Name,
+		Region:        req.Region,
+		Labels:        req.Labels,
+		PriorityClass: req.PriorityClass,
+	}
+	if rp := req.RestartPolicy; rp != nil {
+		createReq.RestartPolicy = &proto.RestartPolicy{Policy: rp.Policy, MaxRetries: rp.MaxRetries}
+	}
+	if g := req.Guest; g != nil {
+		createReq.Guest = &proto.Guest{Cpus: g.CPUs, MemoryMb: g.MemoryMB}
+	}
+	if c := req.Constraints; c != nil {
+		createReq.Constraints = &proto.Constraints{Host: c.Host, AntiAffinity: c.AntiAffinity, SpreadBy: c.SpreadBy}
+	}
+	res, err := h.srv.CreateMachine(ctx, createReq)
 	if err != nil {
-		log.Printf("[create] internal error: %v", err)
+		if status.Code(err) != codes.Unknown {
+			writeRPCError(w, err)
+			return
+		}
+		correlation.Logf(ctx, "[create] internal error: %v", err)
 		writeError(w, http.StatusInternalServerError, "failed to create machine")
 		return
 	}
 
-	if h.publisher != nil {
-		ev := map[string]interface{}{
-			"event":  "machine.created",
-			"id":     res.Id,
-			"name":   req.Name,
-			"region": req.Region,
-			"time":   time.Now().Unix(),
-		}
-		payload, _ := json.Marshal(ev)
-		if err := h.publisher.Publish(ctx, "machines.events", payload); err != nil {
-			log.Printf("[create] publish failed: %v", err)
-		}
-	}
-
-	writeJSON(w, http.StatusOK, map[string]interface{}{
+	out := map[string]interface{}{
 		"id":     res.Id,
 		"status": res.Status,
-	})
+	}
+	if res.StatusReason != "" {
+		out["status_reason"] = res.StatusReason
+	}
+	writeJSON(w, http.StatusOK, out)
 }
 
 func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
@@ -110,39 +172,290 @@ func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	region := machine.Region
-	if h.isPartitioned(region) {
-		writeError(w, http.StatusServiceUnavailable, "region partitioned")
+	writeJSON(w, http.StatusOK, machineJSON(machine))
+}
+
+// handleWait long-polls until the machine reaches ?state=, fails for good or
+// ?timeout= (a Go duration such as 30s) passes. The body is the machine as
+// returned by /get plus the outcome.
+func (h *Handler) handleWait(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var timeout time.Duration
+	if raw := q.Get("timeout"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "timeout must be a duration such as 30s")
+			return
+		}
+		timeout = d
+	}
+	res, err := h.srv.WaitMachine(r.Context(), &proto.WaitRequest{
+		Id:        r.PathValue("id"),
+		Status:    q.Get("state"),
+		TimeoutMs: timeout.Milliseconds(),
+	})
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	out := machineJSON(res.Machine)
+	out["outcome"] = res.Outcome
+	writeJSON(w, http.StatusOK, out)
+}
+
+func machineJSON(machine *proto.GetResponse) map[string]interface{} {
+	out := map[string]interface{}{
+		"id":             machine.Id,
+		"status":         machine.Status,
+		"status_reason":  machine.StatusReason,
+		"priority_class": machine.PriorityClass,
+		"exit_code":      machine.ExitCode,
+		"restart_count":  machine.RestartCount,
+		"host_id":        machine.HostId,
+		"zone":           machine.Zone,
+		"guest": map[string]int32{
+			"cpus":      machine.Guest.GetCpus(),
+			"memory_mb": machine.Guest.GetMemoryMb(),
+		},
+		"labels": machine.Labels,
+		"constraints": map[string]interface{}{
+			"host":          machine.Constraints.GetHost(),
+			"anti_affinity": machine.Constraints.GetAntiAffinity(),
+			"spread_by":     machine.Constraints.GetSpreadBy(),
+		},
+	}
+	if machine.Snapshot != nil {
+		out["snapshot"] = map[string]interface{}{
+			"memory_mb": machine.Snapshot.MemoryMb,
+			"taken_at":  machine.Snapshot.TakenAt,
+		}
+	}
+	return out
+}
+
+// handleMigrate accepts the orchestrator's {"target": region} body as well as
+// {"target_region": region}.
+func (h *Handler) handleMigrate(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Target       string `json:"target"`
+		TargetRegion string `json:"target_region"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON payload")
+		return
+	}
+	if body.TargetRegion == "" {
+		body.TargetRegion = body.Target
+	}
+
+	ctx := r.Context()
+	res, err := h.srv.MigrateMachine(ctx, &proto.MigrateRequest{
+		Id:           r.PathValue("id"),
+		TargetRegion: body.TargetRegion,
+		LeaseNonce:   r.Header.Get(LeaseNonceHeader),
+	})
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"id":            r.PathValue("id"),
+		"target_region": body.TargetRegion,
+		"result":        res.Result,
+	})
+}
+
+// handleAction serves a lifecycle action taking an ActionRequest, passing on
+// the lease nonce header.
+func (h *Handler) handleAction(action func(context.Context, *proto.ActionRequest) (*proto.ActionResponse, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, err := action(r.Context(), &proto.ActionRequest{Id: r.PathValue("id"), LeaseNonce: r.Header.Get(LeaseNonceHeader)})
+		if err != nil {
+			writeRPCError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{
+			"id":     r.PathValue("id"),
+			"result": res.Result,
+		})
+	}
+}
+
+// handleAcquireLease takes a lease with {"ttl": seconds, "owner": ...,
+// "description": ...}, or renews the lease named by the nonce header.
+func (h *Handler) handleAcquireLease(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		TTL         int32  `json:"ttl"`
+		Owner       string `json:"owner"`
+		Description string `json:"description"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON payload")
+			return
+		}
+	}
+	var (
+		lease *proto.Lease
+		err   error
+	)
+	if nonce := r.Header.Get(LeaseNonceHeader); nonce != "" {
+		lease, err = h.srv.RenewLease(r.Context(), &proto.RenewLeaseRequest{Id: r.PathValue("id"), Nonce: nonce, TtlSeconds: body.TTL})
+	} else {
+		lease, err = h.srv.AcquireLease(r.Context(), &proto.AcquireLeaseRequest{
+			Id:          r.PathValue("id"),
+			TtlSeconds:  body.TTL,
+			Owner:       body.Owner,
+			Description: body.Description,
+		})
+	}
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, leaseJSON(lease))
+}
+
+func (h *Handler) handleGetLease(w http.ResponseWriter, r *http.Request) {
+	lease, err := h.srv.GetLease(r.Context(), &proto.GetRequest{Id: r.PathValue("id")})
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, leaseJSON(lease))
+}
+
+func (h *Handler) handleReleaseLease(w http.ResponseWriter, r *http.Request) {
+	res, err := h.srv.ReleaseLease(r.Context(), &proto.ReleaseLeaseRequest{Id: r.PathValue("id"), Nonce: r.Header.Get(LeaseNonceHeader)})
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": r.PathValue("id"), "result": res.Result})
+}
+
+func leaseJSON(l *proto.Lease) map[string]interface{} {
+	return map[string]interface{}{
+		"machine_id":  l.MachineId,
+		"nonce":       l.Nonce,
+		"owner":       l.Owner,
+		"description": l.Description,
+		"expires_at":  l.ExpiresAt,
+	}
+}
+
+func (h *Handler) handleListRegions(w http.ResponseWriter, r *http.Request) {
+	res, err := h.srv.ListRegions(r.Context(), &proto.ListRegionsRequest{})
+	if err != nil {
+		writeRPCError(w, err)
 		return
 	}
+	regions := make([]map[string]interface{}, 0, len(res.Regions))
+	for _, rg := range res.Regions {
+		regions = append(regions, map[string]interface{}{
+			"code":        rg.Code,
+			"name":        rg.Name,
+			"latitude":    rg.Latitude,
+			"longitude":   rg.Longitude,
+			"hosts":       rg.Hosts,
+			"machines":    rg.Machines,
+			"capacity":    map[string]int32{"cpus": rg.Capacity.GetCpus(), "memory_mb": rg.Capacity.GetMemoryMb()},
+			"used":        map[string]int32{"cpus": rg.Used.GetCpus(), "memory_mb": rg.Used.GetMemoryMb()},
+			"partitioned": rg.Partitioned,
+			"latency_ms":  rg.LatencyMs,
+			"queued":      rg.Queued,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"regions": regions})
+}
 
-	if delay := h.getLatencyMs(region); delay > 0 {
-		time.Sleep(time.Duration(delay) * time.Millisecond)
+// handleRTTMatrix returns the inter-region round-trip times in milliseconds
+// as {"regions": [...], "rtt_ms": {"iad": {"ams": 82, ...}, ...}}.
+func (h *Handler) handleRTTMatrix(w http.ResponseWriter, _ *http.Request) {
+	regions, matrix := h.srv.RTTMatrix()
+	rtt := make(map[string]map[string]float64, len(regions))
+	for i, from := range regions {
+		rtt[from] = make(map[string]float64, len(regions))
+		for j, to := range regions {
+			rtt[from][to] = matrix[i][j]
+		}
+	}
+	if regions == nil {
+		regions = []string{}
 	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"regions": regions,
+		"rtt_ms":  rtt,
+	})
+}
 
+func (h *Handler) handleListHosts(w http.ResponseWriter, _ *http.Request) {
+	hosts := h.srv.Hosts()
+	if hosts == nil {
+		hosts = []inventory.Host{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"hosts": hosts})
+}
+
+// handleHostAction serves the host admin actions cordon, uncordon, drain and
+// fail.
+func (h *Handler) handleHostAction(w http.ResponseWriter, r *http.Request) {
+	var rpc func(context.Context, *proto.HostRequest) (*proto.HostResponse, error)
+	switch r.PathValue("action") {
+	case "cordon":
+		rpc = h.srv.CordonHost
+	case "uncordon":
+		rpc = h.srv.UncordonHost
+	case "drain":
+		rpc = h.srv.DrainHost
+	case "fail":
+		rpc = h.srv.FailHost
+	default:
+		writeError(w, http.StatusNotFound, "unknown host action")
+		return
+	}
+	res, err := rpc(r.Context(), &proto.HostRequest{HostId: r.PathValue("id")})
+	if err != nil {
+		writeRPCError(w, err)
+		return
+	}
+	moved, stranded := res.Moved, res.Stranded
+	if moved == nil {
+		moved = []string{}
+	}
+	if stranded == nil {
+		stranded = []string{}
+	}
 	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"id":     machine.Id,
-		"status": machine.Status,
+		"host_id":  res.HostId,
+		"state":    res.State,
+		"moved":    moved,
+		"stranded": stranded,
 	})
 }
 
 func (h *Handler) handlePartition(w http.ResponseWriter, r *http.Request) {
 	var body struct {
-		Region string `json:"region"`
+		Region     string `json:"region"`
+		DurationMs int    `json:"duration_ms"`
 	}
 	_ = json.NewDecoder(r.Body).Decode(&body)
 	if body.Region == "" {
 		writeError(w, http.StatusBadRequest, "region required")
 		return
 	}
+	if body.DurationMs < 0 {
+		writeError(w, http.StatusBadRequest, "duration_ms must be non-negative")
+		return
+	}
 
-	h.mu.Lock()
-	h.partitioned[body.Region] = true
-	h.mu.Unlock()
+	f := h.chaos.Partition(body.Region, time.Duration(body.DurationMs)*time.Millisecond)
 
-	writeJSON(w, http.StatusOK, map[string]string{
-		"status": "partitioned",
-		"region": body.Region,
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":     "partitioned",
+		"region":     body.Region,
+		"fault_id":   f.ID,
+		"expires_at": f.ExpiresAt,
 	})
 }
 
@@ -156,10 +469,7 @@ func (h *Handler) handleHeal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.mu.Lock()
-	delete(h.partitioned, body.Region)
-	delete(h.latencyMs, body.Region)
-	h.mu.Unlock()
+	h.chaos.Heal(body.Region)
 
 	writeJSON(w, http.StatusOK, map[string]string{
 		"status": "healed",
@@ -169,40 +479,98 @@ func (h *Handler) handleHeal(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) handleLatency(w http.ResponseWriter, r *http.Request) {
 	var body struct {
-		Region    string `json:"region"`
-		LatencyMs int    `json:"latency_ms"`
+		Region     string `json:"region"`
+		LatencyMs  int    `json:"latency_ms"`
+		DurationMs int    `json:"duration_ms"`
 	}
 	_ = json.NewDecoder(r.Body).Decode(&body)
 	if body.Region == "" {
 		writeError(w, http.StatusBadRequest, "region required")
 		return
 	}
-	if body.LatencyMs < 0 {
-		writeError(w, http.StatusBadRequest, "latency_ms must be non-negative")
+	if body.LatencyMs < 0 || body.DurationMs < 0 {
+		writeError(w, http.StatusBadRequest, "latency_ms and duration_ms must be non-negative")
 		return
 	}
 
-	h.mu.Lock()
-	h.latencyMs[body.Region] = body.LatencyMs
-	h.mu.Unlock()
+	f := h.chaos.SetLatency(body.Region,
+		time.Duration(body.LatencyMs)*time.Millisecond,
+		time.Duration(body.DurationMs)*time.Millisecond)
 
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"status":     "latency_set",
 		"region":     body.Region,
 		"latency_ms": body.LatencyMs,
+		"fault_id":   f.ID,
+		"expires_at": f.ExpiresAt,
+	})
+}
+
+func (h *Handler) handleChaosState(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, h.chaos.State())
+}
+
+func (h *Handler) handleAddFault(w http.ResponseWriter, r *http.Request) {
+	var f chaos.Fault
+	if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON payload")
+		return
+	}
+	added, err := h.chaos.Add(f)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, added)
+}
+
+func (h *Handler) handleRemoveFault(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !h.chaos.Remove(id) {
+		writeError(w, http.StatusNotFound, "fault not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "removed",
+		"id":     id,
 	})
 }
 
-func (h *Handler) isPartitioned(region string) bool {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-	return h.partitioned[region]
+func (h *Handler) handleListScenarios(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, h.scenarios.List())
+}
+
+// handleStartScenario starts the YAML scenario in the request body.
+func (h *Handler) handleStartScenario(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read scenario")
+		return
+	}
+	sc, err := chaos.ParseScenario(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid scenario: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, h.scenarios.Start(sc))
+}
+
+func (h *Handler) handleGetScenario(w http.ResponseWriter, r *http.Request) {
+	run, ok := h.scenarios.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "scenario not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
 }
 
-func (h *Handler) getLatencyMs(region string) int {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-	return h.latencyMs[region]
+func (h *Handler) handleStopScenario(w http.ResponseWriter, r *http.Request) {
+	run, ok := h.scenarios.Stop(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "scenario not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
 }
 
 func writeJSON(w http.ResponseWriter, status int, v interface{}) {
@@ -211,8 +579,49 @@ func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	_ = json.NewEncoder(w).Encode(v)
 }
 
+// writeRPCError writes a server error with the HTTP status closest to its
+// gRPC code. Precondition failures, such as unsatisfiable placement
+// constraints, list their violations alongside the message.
+func writeRPCError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "machine not found")
+		return
+	}
+	st := status.Convert(err)
+	var violations []map[string]string
+	for _, d := range st.Details() {
+		if pf, ok := d.(*errdetails.PreconditionFailure); ok {
+			for _, v := range pf.Violations {
+				violations = append(violations, map[string]string{
+					"type":        v.Type,
+					"subject":     v.Subject,
+					"description": v.Description,
+				})
+			}
+		}
+	}
+	if violations == nil {
+		writeError(w, chaos.HTTPStatus(st.Code()), st.Message())
+		return
+	}
+	writeErrorWith(w, chaos.HTTPStatus(st.Code()), st.Message(), map[string]interface{}{"violations": violations})
+}
+
 func writeError(w http.ResponseWriter, status int, msg string) {
-	writeJSON(w, status, map[string]string{"error": msg})
+	writeErrorWith(w, status, msg, nil)
+}
+
+// writeErrorWith writes an error body with extra fields next to "error".
+func writeErrorWith(w http.ResponseWriter, status int, msg string, extra map[string]interface{}) {
+	body := map[string]interface{}{"error": msg}
+	for k, v := range extra {
+		body[k] = v
+	}
+	writeJSON(w, status, body)
+	if rid := w.Header().Get(correlation.Header); rid != "" {
+		log.Printf("[rid=%s] [HTTP %d] %s", rid, status, msg)
+		return
+	}
 	log.Printf("[HTTP %d] %s", status, msg)
 }
 
@@ -0,0 +1,528 @@
+// Package chaos holds the fault state shared by every flyd-sim entry point:
+// the gRPC interceptor, the HTTP middleware and the server's background
+// lifecycle transitions all consult the same Engine, so a partitioned region
+// behaves the same way no matter how it is reached.
+//
+// A partition models an isolated host: calls into the region fail, except
+// GetMachine and WaitMachine, which the control plane answers by reporting
+// the machine as unreachable. Machines inside the partition keep booting and
+// crashing.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/clock"
+
+	"google.golang.org/grpc/codes"
+)
+
+var ErrPartitioned = errors.New("region partitioned")
+
+// DefaultSeed seeds the engine RNG when no seed is configured, so two runs
+// with the same faults and traffic inject the same failures.
+const DefaultSeed = 1
+
+// Engine tracks the active faults and decides, with a seeded RNG, which calls
+// they hit.
+type Engine struct {
+	mu     sync.RWMutex
+	faults map[string]*Fault
+	timers map[string]clock.Timer
+	nextID int
+	notify []func(Event)
+	clock  clock.Clock
+
+	rngMu sync.Mutex
+	rng   *rand.Rand
+}
+
+type Option func(*Engine)
+
+// WithSeed seeds the RNG used for every probabilistic decision.
+func WithSeed(seed uint64) Option {
+	return func(e *Engine) { e.rng = rand.New(rand.NewPCG(seed, seed)) }
+}
+
+// WithNotifier registers fn to receive an Event whenever a fault is applied
+// or healed. fn is called without engine locks held.
+func WithNotifier(fn func(Event)) Option {
+	return func(e *Engine) { e.notify = append(e.notify, fn) }
+}
+
+// WithClock runs fault expiry, injected delays and scenarios on c.
+func WithClock(c clock.Clock) Option {
+	return func(e *Engine) { e.clock = c }
+}
+
+// Clock returns the time source the engine's faults run on.
+func (e *Engine) Clock() clock.Clock { return e.clock }
+
+// AddNotifier registers fn like WithNotifier on a running engine.
+func (e *Engine) AddNotifier(fn func(Event)) {
+	e.mu.Lock()
+	e.notify = append(e.notify, fn)
+	e.mu.Unlock()
+}
+
+func NewEngine(opts ...Option) *Engine {
+	e := &Engine{
+		faults: make(map[string]*Fault),
+		timers: make(map[string]clock.Timer),
+		clock:  clock.Real(),
+		rng:    rand.New(rand.NewPCG(DefaultSeed, DefaultSeed)),
+	}
+	for _, o := range opts {
+		o(e)
+	}
+	return e
+}
+
+// Event types reported to the notifier.
+const (
+	EventApplied = "chaos.applied"
+	EventHealed  = "chaos.healed"
+)
+
+// Heal reasons carried by EventHealed.
+const (
+	ReasonExpired  = "expired"
+	ReasonRemoved  = "removed"
+	ReasonHealed   = "healed"
+	ReasonReplaced = "replaced"
+)
+
+// Event reports a fault being applied or healed, or a scenario changing
+// state; Scenario, Loop and Step are set for scenario events.
+type Event struct {
+	Type     string `json:"event"`
+	Fault    Fault  `json:"fault"`
+	Reason   string `json:"reason,omitempty"`
+	Scenario string `json:"scenario,omitempty"`
+	Loop     int    `json:"loop,omitempty"`
+	Step     int    `json:"step,omitempty"`
+}
+
+// Add validates and activates f, assigning it an ID. A fault with a duration
+// heals itself when it expires.
+func (e *Engine) Add(f Fault) (Fault, error) {
+	if err := f.validate(); err != nil {
+		return Fault{}, err
+	}
+	e.mu.Lock()
+	f = e.addLocked(f)
+	e.mu.Unlock()
+	e.emit(Event{Type: EventApplied, Fault: f})
+	return f, nil
+}
+
+func (e *Engine) addLocked(f Fault) Fault {
+	e.nextID++
+	f.ID = fmt.Sprintf("fault-%d", e.nextID)
+	f.seq = e.nextID
+	f.CreatedAt = e.clock.Now().UTC()
+	if f.DurationMs > 0 {
+		d := time.Duration(f.DurationMs) * time.Millisecond
+		expires := f.CreatedAt.Add(d)
+		f.ExpiresAt = &expires
+		id := f.ID
+		e.timers[id] = e.clock.AfterFunc(d, func() { e.remove(id, ReasonExpired) })
+	}
+	e.faults[f.ID] = &f
+	return f
+}
+
+// Remove deactivates the fault with the given ID.
+func (e *Engine) Remove(id string) bool {
+	return e.remove(id, ReasonRemoved)
+}
+
+func (e *Engine) remove(id, reason string) bool {
+	e.mu.Lock()
+	f, ok := e.removeLocked(id)
+	e.mu.Unlock()
+	if ok {
+		e.emit(Event{Type: EventHealed, Fault: f, Reason: reason})
+	}
+	return ok
+}
+
+func (e *Engine) removeLocked(id string) (Fault, bool) {
+	f, ok := e.faults[id]
+	if !ok {
+		return Fault{}, false
+	}
+	delete(e.faults, id)
+	if t, ok := e.timers[id]; ok {
+		t.Stop()
+		delete(e.timers, id)
+	}
+	return *f, true
+}
+
+// Partition cuts region off from every entry point, for d if positive.
+func (e *Engine) Partition(region string, d time.Duration) Fault {
+	return e.replaceRegionFault(Fault{
+		Kind:       KindPartition,
+		Scope:      Scope{Region: region},
+		DurationMs: int(d / time.Millisecond),
+	})
+}
+
+// SetLatency adds a fixed delay to every call into region, for d if positive.
+// A zero latency removes it.
+func (e *Engine) SetLatency(region string, latency, d time.Duration) Fault {
+	if latency <= 0 {
+		e.mu.Lock()
+		healed := e.removeRegionKindLocked(region, KindLatency)
+		e.mu.Unlock()
+		e.emitHealed(healed, ReasonRemoved)
+		return Fault{}
+	}
+	return e.replaceRegionFault(Fault{
+		Kind:       KindLatency,
+		Scope:      Scope{Region: region},
+		LatencyMs:  int(latency / time.Millisecond),
+		DurationMs: int(d / time.Millisecond),
+	})
+}
+
+// replaceRegionFault keeps the one-fault-per-region behaviour of the original
+// partition and latency endpoints.
+func (e *Engine) replaceRegionFault(f Fault) Fault {
+	e.mu.Lock()
+	replaced := e.removeRegionKindLocked(f.Scope.Region, f.Kind)
+	f = e.addLocked(f)
+	e.mu.Unlock()
+	e.emitHealed(replaced, ReasonReplaced)
+	e.emit(Event{Type: EventApplied, Fault: f})
+	return f
+}
+
+func (e *Engine) removeRegionKindLocked(region string, kind Kind) []Fault {
+	var removed []Fault
+	for id, f := range e.faults {
+		if f.Kind == kind && f.Scope == (Scope{Region: region}) {
+			if rf, ok := e.removeLocked(id); ok {
+				removed = append(removed, rf)
+			}
+		}
+	}
+	return removed
+}
+
+// Heal removes every fault scoped to region.
+func (e *Engine) Heal(region string) {
+	e.mu.Lock()
+	var healed []Fault
+	for id, f := range e.faults {
+		if f.Scope.Region == region {
+			if rf, ok := e.removeLocked(id); ok {
+				healed = append(healed, rf)
+			}
+		}
+	}
+	e.mu.Unlock()
+	e.emitHealed(healed, ReasonHealed)
+}
+
+func (e *Engine) emit(ev Event) {
+	e.mu.RLock()
+	notify := e.notify
+	e.mu.RUnlock()
+	for _, fn := range notify {
+		fn(ev)
+	}
+}
+
+func (e *Engine) emitHealed(faults []Fault, reason string) {
+	sort.Slice(faults, func(i, j int) bool { return faults[i].seq < faults[j].seq })
+	for _, f := range faults {
+		e.emit(Event{Type: EventHealed, Fault: f, Reason: reason})
+	}
+}
+
+func (e *Engine) IsPartitioned(region string) bool {
+	return e.Partitioned(Target{Region: region})
+}
+
+// Partitioned reports whether a partition cuts t off.
+func (e *Engine) Partitioned(t Target) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, f := range e.faults {
+		if f.Kind == KindPartition && f.Scope.matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Latency returns the fixed latency configured for region.
+func (e *Engine) Latency(region string) time.Duration {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var d time.Duration
+	for _, f := range e.faults {
+		if f.Kind == KindLatency && f.Scope.matches(Target{Region: region}) {
+			d += f.latency()
+		}
+	}
+	return d
+}
+
+// matching returns copies of the faults that apply to t, oldest first so that
+// RNG draws happen in a stable order.
+func (e *Engine) matching(t Target) []Fault {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var out []Fault
+	for _, f := range e.faults {
+		if f.Scope.matches(t) {
+			out = append(out, *f)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].seq < out[j].seq })
+	return out
+}
+
+// observeMethods are answered during a partition instead of failing, so
+// callers can see the machine is unreachable.
+var observeMethods = map[string]bool{
+	"GetMachine":  true,
+	"WaitMachine": true,
+}
+
+// Apply injects the faults matching t into a call. It waits out latency and
+// jitter, blocks dropped calls until they time out, and returns an
+// *InjectedError for partitions and injected errors. The returned bandwidth
+// limit, in kbps, is 0 when no slowdown applies.
+func (e *Engine) Apply(ctx context.Context, t Target) (kbps int, err error) {
+	var delay time.Duration
+	for _, f := range e.matching(t) {
+		switch f.Kind {
+		case KindPartition:
+			if observeMethods[t.Method] {
+				continue
+			}
+			return 0, &InjectedError{Code: codes.Unavailable, Message: ErrPartitioned.Error(), partition: true}
+		case KindError:
+			if e.chance(f.Probability) {
+				return 0, &InjectedError{Code: f.Code, Message: fmt.Sprintf("injected %s by %s", f.Code, f.ID)}
+			}
+		case KindDrop:
+			if e.chance(f.Probability) {
+				return 0, e.drop(ctx, f)
+			}
+		case KindLatency, KindJitter:
+			delay += e.delay(f)
+		case KindBandwidth:
+			if kbps == 0 || f.BandwidthKbps < kbps {
+				kbps = f.BandwidthKbps
+			}
+		}
+	}
+	if err := e.clock.Sleep(ctx, delay); err != nil {
+		return 0, err
+	}
+	return kbps, nil
+}
+
+// Crash decides whether a crash fault strikes the machine t over the last
+// elapsed interval, treating failures as a Poisson process with the fault's
+// MTBF. It returns the exit code to report when it does.
+func (e *Engine) Crash(t Target, elapsed time.Duration) (exitCode int, crashed bool) {
+	for _, f := range e.matching(t) {
+		if f.Kind != KindCrash {
+			continue
+		}
+		p := 1 - math.Exp(-float64(elapsed)/float64(time.Duration(f.MTBFMs)*time.Millisecond))
+		if e.chance(p) {
+			return f.ExitCodes[e.intn(len(f.ExitCodes))], true
+		}
+	}
+	return 0, false
+}
+
+// Storage applies the storage faults matching t to one store operation. It
+// waits out added latency and returns an *InjectedError when the operation
+// should fail; otherwise, for reads, it returns ModeTorn or ModeStale when
+// the result should be corrupted that way, or "" to leave it alone.
+func (e *Engine) Storage(ctx context.Context, t Target, write bool) (string, error) {
+	op := OpRead
+	if write {
+		op = OpWrite
+	}
+	var delay time.Duration
+	var corrupt string
+	for _, f := range e.matching(t) {
+		if f.Kind != KindStorage || (f.Op != "" && f.Op != op) {
+			continue
+		}
+		switch f.Mode {
+		case ModeLatency:
+			delay += f.latency()
+		case ModeError:
+			if e.chance(f.Probability) {
+				return "", &InjectedError{Code: f.Code, Message: fmt.Sprintf("injected storage %s failure by %s", op, f.ID)}
+			}
+		case ModeTorn, ModeStale:
+			if corrupt == "" && e.chance(f.Probability) {
+				corrupt = f.Mode
+			}
+		}
+	}
+	if err := e.clock.Sleep(ctx, delay); err != nil {
+		return "", err
+	}
+	return corrupt, nil
+}
+
+// Delivery is what the delivery faults do to one event.
+type Delivery struct {
+	Drop      bool
+	Duplicate bool
+	Reorder   bool
+	Delay     time.Duration
+}
+
+// Delivery rolls the delivery faults matching t for one event. A dropped
+// event ignores the other faults.
+func (e *Engine) Delivery(t Target) Delivery {
+	var d Delivery
+	for _, f := range e.matching(t) {
+		if f.Kind != KindDelivery || !e.chance(f.Probability) {
+			continue
+		}
+		switch f.Mode {
+		case ModeDrop:
+			return Delivery{Drop: true}
+		case ModeDuplicate:
+			d.Duplicate = true
+		case ModeReorder:
+			d.Reorder = true
+		case ModeDelay:
+			if f.JitterMs > 0 {
+				e.rngMu.Lock()
+				d.Delay += f.jitter(e.rng)
+				e.rngMu.Unlock()
+			} else {
+				d.Delay += f.latency()
+			}
+		}
+	}
+	return d
+}
+
+// Skew is how far the clock of the host behind t is off.
+func (e *Engine) Skew(t Target) time.Duration {
+	var d time.Duration
+	for _, f := range e.matching(t) {
+		if f.Kind == KindSkew {
+			d += time.Duration(f.SkewMs) * time.Millisecond
+		}
+	}
+	return d
+}
+
+// Delay waits out the latency and jitter faults matching t. Background
+// transitions use it so slow regions boot slowly; partitions do not hold
+// them up.
+func (e *Engine) Delay(ctx context.Context, t Target) error {
+	return e.clock.Sleep(ctx, e.SampleDelay(t))
+}
+
+// SampleDelay draws the delay the latency and jitter faults matching t would
+// add to one call, without waiting it out.
+func (e *Engine) SampleDelay(t Target) time.Duration {
+	var delay time.Duration
+	for _, f := range e.matching(t) {
+		if f.Kind == KindLatency || f.Kind == KindJitter {
+			delay += e.delay(f)
+		}
+	}
+	return delay
+}
+
+// State is a point-in-time copy of the active faults.
+type State struct {
+	Faults []Fault `json:"faults"`
+}
+
+// State lists the active faults with the time each has left to run.
+func (e *Engine) State() State {
+	faults := e.matchingAll()
+	now := e.clock.Now()
+	for i := range faults {
+		if faults[i].ExpiresAt != nil {
+			left := faults[i].ExpiresAt.Sub(now)
+			if left < 0 {
+				left = 0
+			}
+			faults[i].RemainingMs = left.Milliseconds()
+		}
+	}
+	return State{Faults: faults}
+}
+
+func (e *Engine) matchingAll() []Fault {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := make([]Fault, 0, len(e.faults))
+	for _, f := range e.faults {
+		out = append(out, *f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].seq < out[j].seq })
+	return out
+}
+
+func (e *Engine) chance(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	e.rngMu.Lock()
+	defer e.rngMu.Unlock()
+	return e.rng.Float64() < p
+}
+
+// Float64 draws from the engine's seeded RNG, so noise added outside the
+// engine is reproduced by the same -chaos-seed too.
+func (e *Engine) Float64() float64 {
+	e.rngMu.Lock()
+	defer e.rngMu.Unlock()
+	return e.rng.Float64()
+}
+
+func (e *Engine) intn(n int) int {
+	e.rngMu.Lock()
+	defer e.rngMu.Unlock()
+	return e.rng.IntN(n)
+}
+
+func (e *Engine) delay(f Fault) time.Duration {
+	if f.Kind == KindLatency {
+		return f.latency()
+	}
+	e.rngMu.Lock()
+	d := f.jitter(e.rng)
+	e.rngMu.Unlock()
+	return d
+}
+
+func (e *Engine) drop(ctx context.Context, f Fault) error {
+	timeout := time.Duration(f.TimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultDropTimeout
+	}
+	if err := e.clock.Sleep(ctx, timeout); err != nil {
+		return err
+	}
+	return &InjectedError{Code: codes.DeadlineExceeded, Message: fmt.Sprintf("request dropped by %s", f.ID)}
+}
@@ -0,0 +1,356 @@
+package chaos
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultDropTimeout is how long a dropped call hangs when the fault does not
+// set one and the caller has no deadline of its own.
+const defaultDropTimeout = 30 * time.Second
+
+type Kind string
+
+const (
+	// KindPartition rejects every call with Unavailable.
+	KindPartition Kind = "partition"
+	// KindLatency adds a fixed delay.
+	KindLatency Kind = "latency"
+	// KindJitter adds a random delay drawn from Distribution.
+	KindJitter Kind = "jitter"
+	// KindError fails calls with Code at the given Probability.
+	KindError Kind = "error"
+	// KindDrop swallows calls at the given Probability; they hang until
+	// TimeoutMs or the caller's deadline and then fail.
+	KindDrop Kind = "drop"
+	// KindBandwidth slows responses down to BandwidthKbps.
+	KindBandwidth Kind = "bandwidth"
+	// KindCrash makes running machines exit on their own with a mean time
+	// between failures of MTBFMs. Only region and machine scopes apply.
+	KindCrash Kind = "crash"
+	// KindStorage disturbs the machine store rather than RPCs: Mode picks
+	// failed operations, added latency, or torn and stale reads, and Op
+	// limits it to reads or writes. Scope.Method matches store methods
+	// such as "SaveMachine".
+	KindStorage Kind = "storage"
+	// KindDelivery disturbs machine event delivery: Mode drops, duplicates,
+	// delays or reorders events at the given Probability. Scope.Method
+	// matches the event type, e.g. "running".
+	KindDelivery Kind = "delivery"
+	// KindSkew shifts the clock of the hosts in scope by SkewMs, which may
+	// be negative. It moves the timestamps they record and report.
+	KindSkew Kind = "skew"
+)
+
+// Storage fault modes.
+const (
+	ModeError   = "error"
+	ModeLatency = "latency"
+	// ModeTorn returns a record mixing the latest write with the one
+	// before it, as if the read raced a partially applied write.
+	ModeTorn = "torn"
+	// ModeStale returns the record as it was before the latest write.
+	ModeStale = "stale"
+)
+
+// Delivery fault modes. A delayed event waits LatencyMs, or a jitter draw
+// when JitterMs is set; a reordered event is held back until the next one
+// goes out.
+const (
+	ModeDrop      = "drop"
+	ModeDuplicate = "duplicate"
+	ModeDelay     = "delay"
+	ModeReorder   = "reorder"
+)
+
+// Storage operations a storage fault can be limited to.
+const (
+	OpRead  = "read"
+	OpWrite = "write"
+)
+
+// Distributions accepted by jitter faults.
+const (
+	DistUniform     = "uniform"
+	DistNormal      = "normal"
+	DistExponential = "exponential"
+	DistPareto      = "pareto"
+)
+
+// Scope limits a fault to a region, RPC method and/or machine. Empty fields
+// match everything, so a zero Scope applies to every call.
+type Scope struct {
+	Region    string `json:"region,omitempty"`
+	Method    string `json:"method,omitempty"`
+	MachineID string `json:"machine_id,omitempty"`
+}
+
+// Matches reports whether t falls within the scope.
+func (s Scope) Matches(t Target) bool {
+	return s.matches(t)
+}
+
+func (s Scope) matches(t Target) bool {
+	return (s.Region == "" || s.Region == t.Region) &&
+		(s.Method == "" || s.Method == t.Method) &&
+		(s.MachineID == "" || s.MachineID == t.MachineID)
+}
+
+// Target describes the call a fault is evaluated against. Method is the RPC
+// name (e.g. "StartMachine"); HTTP routes are mapped onto the same names.
+type Target struct {
+	Region    string
+	Method    string
+	MachineID string
+}
+
+// Fault is a single injected failure mode.
+type Fault struct {
+	ID    string `json:"id"`
+	Kind  Kind   `json:"kind"`
+	Scope Scope  `json:"scope"`
+
+	// LatencyMs is the fixed delay of a latency fault and the mean delay of
+	// a jitter fault.
+	LatencyMs int `json:"latency_ms,omitempty"`
+	// JitterMs is the spread of a jitter fault: the half-width for uniform,
+	// the standard deviation for normal and the mean of the added tail for
+	// exponential and pareto.
+	JitterMs     int    `json:"jitter_ms,omitempty"`
+	Distribution string `json:"distribution,omitempty"`
+
+	// Probability applies to error and drop faults.
+	Probability float64    `json:"probability,omitempty"`
+	Code        codes.Code `json:"code,omitempty"`
+	TimeoutMs   int        `json:"timeout_ms,omitempty"`
+
+	BandwidthKbps int `json:"bandwidth_kbps,omitempty"`
+
+	// MTBFMs is the mean time between failures of a crash fault; ExitCodes
+	// are drawn from uniformly on each crash (default 1). A zero exit code
+	// is a clean exit rather than a crash.
+	MTBFMs    int   `json:"mtbf_ms,omitempty"`
+	ExitCodes []int `json:"exit_codes,omitempty"`
+
+	SkewMs int `json:"skew_ms,omitempty"`
+
+	// Mode and Op configure storage and delivery faults; Probability
+	// applies to every mode except storage latency.
+	Mode string `json:"mode,omitempty"`
+	Op   string `json:"op,omitempty"`
+
+	// Source names the scenario run that applied the fault, if any.
+	Source string `json:"source,omitempty"`
+
+	// DurationMs makes the fault heal itself after that long; zero keeps it
+	// until it is removed.
+	DurationMs  int        `json:"duration_ms,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RemainingMs int64      `json:"remaining_ms,omitempty"`
+
+	seq int
+}
+
+func (f *Fault) validate() error {
+	if f.DurationMs < 0 {
+		return fmt.Errorf("duration_ms must be non-negative")
+	}
+	switch f.Kind {
+	case KindPartition:
+		if f.Scope.Region == "" {
+			return fmt.Errorf("partition requires a region scope")
+		}
+	case KindLatency:
+		if f.LatencyMs <= 0 {
+			return fmt.Errorf("latency_ms must be positive")
+		}
+	case KindJitter:
+		if f.JitterMs <= 0 || f.LatencyMs < 0 {
+			return fmt.Errorf("jitter_ms must be positive and latency_ms non-negative")
+		}
+		switch f.Distribution {
+		case "":
+			f.Distribution = DistUniform
+		case DistUniform, DistNormal, DistExponential, DistPareto:
+		default:
+			return fmt.Errorf("unknown distribution %q", f.Distribution)
+		}
+	case KindError:
+		if f.Code == codes.OK {
+			f.Code = codes.Unavailable
+		}
+		fallthrough
+	case KindDrop:
+		if f.Probability <= 0 || f.Probability > 1 {
+			return fmt.Errorf("probability must be in (0, 1]")
+		}
+		if f.TimeoutMs < 0 {
+			return fmt.Errorf("timeout_ms must be non-negative")
+		}
+	case KindBandwidth:
+		if f.BandwidthKbps <= 0 {
+			return fmt.Errorf("bandwidth_kbps must be positive")
+		}
+	case KindCrash:
+		if f.MTBFMs <= 0 {
+			return fmt.Errorf("mtbf_ms must be positive")
+		}
+		if f.Scope.Method != "" {
+			return fmt.Errorf("crash faults cannot be scoped by method")
+		}
+		if len(f.ExitCodes) == 0 {
+			f.ExitCodes = []int{1}
+		}
+	case KindStorage:
+		switch f.Op {
+		case "", OpRead, OpWrite:
+		default:
+			return fmt.Errorf("unknown storage op %q", f.Op)
+		}
+		switch f.Mode {
+		case ModeLatency:
+			if f.LatencyMs <= 0 {
+				return fmt.Errorf("latency_ms must be positive")
+			}
+			return nil
+		case ModeError:
+			if f.Code == codes.OK {
+				f.Code = codes.Internal
+			}
+		case ModeTorn, ModeStale:
+			if f.Op == OpWrite {
+				return fmt.Errorf("%s storage faults only apply to reads", f.Mode)
+			}
+			f.Op = OpRead
+		default:
+			return fmt.Errorf("unknown storage mode %q", f.Mode)
+		}
+		if f.Probability <= 0 || f.Probability > 1 {
+			return fmt.Errorf("probability must be in (0, 1]")
+		}
+	case KindDelivery:
+		switch f.Mode {
+		case ModeDrop, ModeDuplicate, ModeReorder:
+		case ModeDelay:
+			if f.LatencyMs < 0 || f.JitterMs < 0 || f.LatencyMs+f.JitterMs == 0 {
+				return fmt.Errorf("delay needs a positive latency_ms or jitter_ms")
+			}
+			switch f.Distribution {
+			case "":
+				f.Distribution = DistUniform
+			case DistUniform, DistNormal, DistExponential, DistPareto:
+			default:
+				return fmt.Errorf("unknown distribution %q", f.Distribution)
+			}
+		default:
+			return fmt.Errorf("unknown delivery mode %q", f.Mode)
+		}
+		if f.Probability <= 0 || f.Probability > 1 {
+			return fmt.Errorf("probability must be in (0, 1]")
+		}
+	case KindSkew:
+		if f.SkewMs == 0 {
+			return fmt.Errorf("skew_ms must be non-zero")
+		}
+		if f.Scope.Method != "" {
+			return fmt.Errorf("skew faults cannot be scoped by method")
+		}
+	default:
+		return fmt.Errorf("unknown fault kind %q", f.Kind)
+	}
+	return nil
+}
+
+func (f Fault) latency() time.Duration {
+	return time.Duration(f.LatencyMs) * time.Millisecond
+}
+
+// jitter draws a delay around LatencyMs from the fault's distribution. The
+// result is never negative.
+func (f Fault) jitter(rng *rand.Rand) time.Duration {
+	mean := float64(f.LatencyMs)
+	spread := float64(f.JitterMs)
+	var ms float64
+	switch f.Distribution {
+	case DistNormal:
+		ms = mean + rng.NormFloat64()*spread
+	case DistExponential:
+		ms = mean + rng.ExpFloat64()*spread
+	case DistPareto:
+		// Shape 2 gives a heavy tail whose mean excess equals spread.
+		const alpha = 2.0
+		scale := spread * (alpha - 1)
+		ms = mean + scale/math.Pow(1-rng.Float64(), 1/alpha) - scale
+	default:
+		ms = mean + (rng.Float64()*2-1)*spread
+	}
+	if ms < 0 {
+		ms = 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// TransferDelay is how long n bytes take at kbps.
+func TransferDelay(n, kbps int) time.Duration {
+	if kbps <= 0 || n <= 0 {
+		return 0
+	}
+	return time.Duration(float64(n*8) / float64(kbps) * float64(time.Millisecond))
+}
+
+// InjectedError is returned for calls failed by a fault. It carries the gRPC
+// code to surface and maps onto an HTTP status for the REST shim.
+type InjectedError struct {
+	Code    codes.Code
+	Message string
+
+	partition bool
+}
+
+func (e *InjectedError) Error() string { return e.Message }
+
+func (e *InjectedError) Is(target error) bool {
+	return target == ErrPartitioned && e.partition
+}
+
+func (e *InjectedError) GRPCStatus() *status.Status {
+	return status.New(e.Code, e.Message)
+}
+
+// HTTPStatus maps the error's gRPC code onto the closest HTTP status.
+func (e *InjectedError) HTTPStatus() int {
+	return HTTPStatus(e.Code)
+}
+
+// HTTPStatus maps a gRPC code onto the closest HTTP status.
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
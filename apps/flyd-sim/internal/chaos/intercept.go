@@ -0,0 +1,139 @@
+package chaos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/clock"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// RegionResolver maps a machine ID to the region it lives in.
+type RegionResolver func(ctx context.Context, id string) (string, error)
+
+type regionGetter interface{ GetRegion() string }
+type idGetter interface{ GetId() string }
+
+// target builds the Target for a call. An explicit region wins, otherwise
+// the machine ID is resolved; unknown machines get no region so the handler
+// can report the real error.
+func target(ctx context.Context, resolve RegionResolver, method, region, id string) Target {
+	t := Target{Region: region, Method: method, MachineID: id}
+	if t.Region == "" && id != "" && resolve != nil {
+		if r, err := resolve(ctx, id); err == nil {
+			t.Region = r
+		}
+	}
+	return t
+}
+
+// UnaryServerInterceptor enforces chaos faults on gRPC calls. Faults scoped
+// by method match the bare RPC name, e.g. "StartMachine".
+func (e *Engine) UnaryServerInterceptor(resolve RegionResolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var region, id string
+		if r, ok := req.(regionGetter); ok {
+			region = r.GetRegion()
+		}
+		if r, ok := req.(idGetter); ok {
+			id = r.GetId()
+		}
+		t := target(ctx, resolve, path.Base(info.FullMethod), region, id)
+		kbps, err := e.Apply(ctx, t)
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		resp, err := handler(ctx, req)
+		if kbps > 0 {
+			size := 0
+			if m, ok := req.(proto.Message); ok {
+				size += proto.Size(m)
+			}
+			if m, ok := resp.(proto.Message); ok && err == nil {
+				size += proto.Size(m)
+			}
+			if serr := e.clock.Sleep(ctx, TransferDelay(size, kbps)); serr != nil {
+				return nil, grpcError(serr)
+			}
+		}
+		return resp, err
+	}
+}
+
+func grpcError(err error) error {
+	var inj *InjectedError
+	if errors.As(err, &inj) {
+		return inj.GRPCStatus().Err()
+	}
+	return status.FromContextError(err).Err()
+}
+
+// Middleware enforces chaos faults on one HTTP route. method is the RPC name
+// the route corresponds to, so method-scoped faults hit both entry points
+// alike. The target machine comes from the {id} path value or "id" query
+// parameter, the region from the "region" query parameter; failing both, the
+// same fields of a JSON body are used.
+func (e *Engine) Middleware(resolve RegionResolver, method string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		region, id := q.Get("region"), r.PathValue("id")
+		if id == "" {
+			id = q.Get("id")
+		}
+		if region == "" && id == "" && r.Body != nil && r.Body != http.NoBody {
+			body, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			if err == nil {
+				var t struct {
+					Region string `json:"region"`
+					ID     string `json:"id"`
+				}
+				_ = json.Unmarshal(body, &t)
+				region, id = t.Region, t.ID
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		kbps, err := e.Apply(r.Context(), target(r.Context(), resolve, method, region, id))
+		if err != nil {
+			code := http.StatusGatewayTimeout
+			var inj *InjectedError
+			if errors.As(err, &inj) {
+				code = inj.HTTPStatus()
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			log.Printf("[chaos] %s %s rejected: %v", r.Method, r.URL.Path, err)
+			return
+		}
+		if kbps > 0 {
+			w = &throttledWriter{ResponseWriter: w, ctx: r.Context(), clock: e.clock, kbps: kbps}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// throttledWriter paces response writes to a bandwidth limit.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx   context.Context
+	clock clock.Clock
+	kbps  int
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	if err := t.clock.Sleep(t.ctx, TransferDelay(len(p), t.kbps)); err != nil {
+		return 0, err
+	}
+	return t.ResponseWriter.Write(p)
+}
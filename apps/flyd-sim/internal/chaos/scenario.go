@@ -0,0 +1,359 @@
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a declarative chaos timeline, usually loaded from YAML:
+//
+//	name: iad-outage
+//	loops: 2
+//	steps:
+//	  - at: 30s
+//	    fault: {kind: partition, scope: {region: iad}}
+//	    duration: 60s
+//	  - at: 90s
+//	    fault: {kind: latency, scope: {region: ams}, latency_ms: 300}
+//	    duration: 30s
+//	  - at: 2m
+//	    choose:
+//	      - fault: {kind: partition, scope: {region: sin}}
+//	        duration: 20s
+//	      - fault: {kind: error, probability: 0.2, code: UNAVAILABLE}
+//	        duration: 20s
+//
+// Faults use the same fields as POST /chaos/faults. Loops is the number of
+// times the timeline runs (default once, -1 until stopped); choose picks one
+// option at random with the engine's seeded RNG.
+type Scenario struct {
+	Name  string `yaml:"name" json:"name"`
+	Loops int    `yaml:"loops" json:"loops,omitempty"`
+	Steps []Step `yaml:"steps" json:"steps"`
+}
+
+// Step applies one fault at an offset from the start of each loop.
+type Step struct {
+	Name     string        `yaml:"name" json:"name,omitempty"`
+	At       time.Duration `yaml:"at" json:"at"`
+	Duration time.Duration `yaml:"duration" json:"duration,omitempty"`
+	Fault    *Fault        `yaml:"-" json:"fault,omitempty"`
+	Choose   []Step        `yaml:"choose" json:"choose,omitempty"`
+}
+
+// UnmarshalYAML decodes the fault through its JSON form so scenario files and
+// the faults API accept exactly the same fields and values.
+func (s *Step) UnmarshalYAML(value *yaml.Node) error {
+	type plain Step
+	var raw struct {
+		plain `yaml:",inline"`
+		Fault map[string]interface{} `yaml:"fault"`
+	}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	*s = Step(raw.plain)
+	if raw.Fault != nil {
+		data, err := json.Marshal(raw.Fault)
+		if err != nil {
+			return err
+		}
+		var f Fault
+		if err := json.Unmarshal(data, &f); err != nil {
+			return fmt.Errorf("line %d: fault: %w", value.Line, err)
+		}
+		s.Fault = &f
+	}
+	return nil
+}
+
+// ParseScenario parses and validates a YAML scenario.
+func ParseScenario(data []byte) (*Scenario, error) {
+	var sc Scenario
+	if err := yaml.Unmarshal(data, &sc); err != nil {
+		return nil, err
+	}
+	if err := sc.validate(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(sc.Steps, func(i, j int) bool { return sc.Steps[i].At < sc.Steps[j].At })
+	return &sc, nil
+}
+
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScenario(data)
+}
+
+func (sc *Scenario) validate() error {
+	if sc.Name == "" {
+		return errors.New("scenario name required")
+	}
+	if len(sc.Steps) == 0 {
+		return errors.New("scenario has no steps")
+	}
+	if sc.Loops < -1 {
+		return errors.New("loops must be -1 (forever) or positive")
+	}
+	for i := range sc.Steps {
+		st := &sc.Steps[i]
+		if st.At < 0 {
+			return fmt.Errorf("step %d: at must be non-negative", i)
+		}
+		if (st.Fault == nil) == (len(st.Choose) == 0) {
+			return fmt.Errorf("step %d: exactly one of fault or choose is required", i)
+		}
+		options := st.Choose
+		if st.Fault != nil {
+			options = []Step{*st}
+		}
+		for j, opt := range options {
+			if opt.Fault == nil {
+				return fmt.Errorf("step %d option %d: fault required", i, j)
+			}
+			f := *opt.Fault
+			f.DurationMs = int(opt.Duration / time.Millisecond)
+			if err := f.validate(); err != nil {
+				return fmt.Errorf("step %d option %d: %w", i, j, err)
+			}
+		}
+	}
+	// A zero-length timeline looped forever would reapply its faults
+	// without pause.
+	if sc.Loops == -1 && sc.loopLength() <= 0 {
+		return errors.New("loops -1 needs a step with a positive at or duration")
+	}
+	return nil
+}
+
+// loopLength is how long one pass of the timeline takes: until the last step
+// starts and its longest-running fault has healed.
+func (sc *Scenario) loopLength() time.Duration {
+	var end time.Duration
+	for _, st := range sc.Steps {
+		d := st.Duration
+		for _, opt := range st.Choose {
+			if opt.Duration > d {
+				d = opt.Duration
+			}
+		}
+		if st.At+d > end {
+			end = st.At + d
+		}
+	}
+	return end
+}
+
+// Scenario run states.
+const (
+	RunRunning  = "running"
+	RunFinished = "finished"
+	RunStopped  = "stopped"
+)
+
+// Scenario events reported to the engine notifier.
+const (
+	EventScenarioStarted  = "chaos.scenario.started"
+	EventScenarioStep     = "chaos.scenario.step"
+	EventScenarioFinished = "chaos.scenario.finished"
+	EventScenarioStopped  = "chaos.scenario.stopped"
+)
+
+// ScenarioRun is the externally visible state of a started scenario.
+type ScenarioRun struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	Loop      int       `json:"loop"`
+	Step      int       `json:"step"`
+	StartedAt time.Time `json:"started_at"`
+	Scenario  *Scenario `json:"scenario"`
+}
+
+type scenarioRun struct {
+	ScenarioRun
+	cancel context.CancelFunc
+	faults map[string]bool
+}
+
+// ScenarioRunner starts, stops and reports scenario runs against an Engine.
+type ScenarioRunner struct {
+	engine *Engine
+
+	mu     sync.Mutex
+	runs   map[string]*scenarioRun
+	nextID int
+}
+
+func NewScenarioRunner(e *Engine) *ScenarioRunner {
+	r := &ScenarioRunner{engine: e, runs: make(map[string]*scenarioRun)}
+	e.AddNotifier(r.onEvent)
+	return r
+}
+
+// onEvent forgets faults a run applied once they expire or are removed, so
+// long-running scenarios do not accumulate them.
+func (r *ScenarioRunner) onEvent(ev Event) {
+	if ev.Type != EventHealed || ev.Fault.Source == "" {
+		return
+	}
+	r.mu.Lock()
+	if run, ok := r.runs[ev.Fault.Source]; ok {
+		delete(run.faults, ev.Fault.ID)
+	}
+	r.mu.Unlock()
+}
+
+// Start runs sc in the background and returns its initial state.
+func (r *ScenarioRunner) Start(sc *Scenario) ScenarioRun {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.mu.Lock()
+	r.nextID++
+	run := &scenarioRun{
+		ScenarioRun: ScenarioRun{
+			ID:        fmt.Sprintf("scenario-%d", r.nextID),
+			Name:      sc.Name,
+			State:     RunRunning,
+			StartedAt: r.engine.clock.Now().UTC(),
+			Scenario:  sc,
+		},
+		cancel: cancel,
+		faults: make(map[string]bool),
+	}
+	r.runs[run.ID] = run
+	snapshot := run.ScenarioRun
+	r.mu.Unlock()
+
+	r.engine.emit(Event{Type: EventScenarioStarted, Scenario: run.ID})
+	go r.run(ctx, run)
+	return snapshot
+}
+
+// Stop cancels a running scenario and heals the faults it applied.
+func (r *ScenarioRunner) Stop(id string) (ScenarioRun, bool) {
+	r.mu.Lock()
+	run, ok := r.runs[id]
+	if !ok {
+		r.mu.Unlock()
+		return ScenarioRun{}, false
+	}
+	wasRunning := run.State == RunRunning
+	if wasRunning {
+		run.State = RunStopped
+	}
+	snapshot := run.ScenarioRun
+	r.mu.Unlock()
+
+	run.cancel()
+	r.heal(run)
+	if wasRunning {
+		r.engine.emit(Event{Type: EventScenarioStopped, Scenario: id})
+	}
+	return snapshot, true
+}
+
+func (r *ScenarioRunner) Get(id string) (ScenarioRun, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run, ok := r.runs[id]
+	if !ok {
+		return ScenarioRun{}, false
+	}
+	return run.ScenarioRun, true
+}
+
+func (r *ScenarioRunner) List() []ScenarioRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]ScenarioRun, 0, len(r.runs))
+	for _, run := range r.runs {
+		out = append(out, run.ScenarioRun)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+func (r *ScenarioRunner) run(ctx context.Context, run *scenarioRun) {
+	sc := run.Scenario
+	loops := sc.Loops
+	if loops == 0 {
+		loops = 1
+	}
+	for loop := 1; loops < 0 || loop <= loops; loop++ {
+		clk := r.engine.clock
+		start := clk.Now()
+		for i, st := range sc.Steps {
+			if err := clk.Sleep(ctx, start.Add(st.At).Sub(clk.Now())); err != nil {
+				return
+			}
+			r.applyStep(ctx, run, loop, i, st)
+		}
+		if err := clk.Sleep(ctx, start.Add(sc.loopLength()).Sub(clk.Now())); err != nil {
+			return
+		}
+	}
+
+	r.mu.Lock()
+	finished := run.State == RunRunning
+	if finished {
+		run.State = RunFinished
+	}
+	r.mu.Unlock()
+	r.heal(run)
+	if finished {
+		r.engine.emit(Event{Type: EventScenarioFinished, Scenario: run.ID})
+	}
+}
+
+// heal removes the faults a run applied that have not expired yet.
+func (r *ScenarioRunner) heal(run *scenarioRun) {
+	r.mu.Lock()
+	faults := make([]string, 0, len(run.faults))
+	for fid := range run.faults {
+		faults = append(faults, fid)
+	}
+	run.faults = map[string]bool{}
+	r.mu.Unlock()
+
+	sort.Strings(faults)
+	for _, fid := range faults {
+		r.engine.Remove(fid)
+	}
+}
+
+func (r *ScenarioRunner) applyStep(ctx context.Context, run *scenarioRun, loop, index int, st Step) {
+	if len(st.Choose) > 0 {
+		st = st.Choose[r.engine.intn(len(st.Choose))]
+	}
+	f := *st.Fault
+	f.DurationMs = int(st.Duration / time.Millisecond)
+	f.Source = run.ID
+
+	r.mu.Lock()
+	if ctx.Err() != nil {
+		r.mu.Unlock()
+		return
+	}
+	added, err := r.engine.Add(f)
+	if err == nil {
+		run.faults[added.ID] = true
+	}
+	run.Loop, run.Step = loop, index
+	r.mu.Unlock()
+
+	if err != nil {
+		return
+	}
+	r.engine.emit(Event{Type: EventScenarioStep, Fault: added, Scenario: run.ID, Step: index, Loop: loop})
+}
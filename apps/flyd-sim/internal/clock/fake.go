@@ -0,0 +1,163 @@
+package clock
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when Advance is called. Sleepers, timers
+// and tickers fire in deadline order as time passes them.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	seq     int
+	waiters []*waiter
+	// changed is closed and replaced whenever a waiter is added, waking
+	// BlockUntil callers.
+	changed chan struct{}
+}
+
+type waiter struct {
+	at     time.Time
+	seq    int
+	period time.Duration
+	fn     func()
+	ch     chan time.Time
+}
+
+// NewFake returns a fake clock reading now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now, changed: make(chan struct{})}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	w := &waiter{ch: make(chan time.Time, 1)}
+	f.add(w, d)
+	select {
+	case <-ctx.Done():
+		f.remove(w)
+		return ctx.Err()
+	case <-w.ch:
+		return nil
+	}
+}
+
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	w := &waiter{fn: fn}
+	f.add(w, d)
+	return fakeTimer{f, w}
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	w := &waiter{period: d, ch: make(chan time.Time, 1)}
+	f.add(w, d)
+	return fakeTicker{f, w}
+}
+
+// Advance moves the clock forward by d, firing everything that falls due on
+// the way. Tickers fire once per elapsed period, dropping ticks the reader
+// has not taken, like time.Ticker.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	end := f.now.Add(d)
+	for {
+		sort.Slice(f.waiters, func(i, j int) bool {
+			a, b := f.waiters[i], f.waiters[j]
+			if !a.at.Equal(b.at) {
+				return a.at.Before(b.at)
+			}
+			return a.seq < b.seq
+		})
+		if len(f.waiters) == 0 || f.waiters[0].at.After(end) {
+			break
+		}
+		w := f.waiters[0]
+		f.now = w.at
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			f.waiters = f.waiters[1:]
+		}
+		now := f.now
+		f.mu.Unlock()
+		switch {
+		case w.fn != nil:
+			go w.fn()
+		default:
+			select {
+			case w.ch <- now:
+			default:
+			}
+		}
+		f.mu.Lock()
+	}
+	f.now = end
+	f.mu.Unlock()
+}
+
+// BlockUntil waits until at least n sleepers, timers and tickers are pending,
+// so a test can advance the clock knowing the code under test is waiting.
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mu.Lock()
+		pending := len(f.waiters)
+		changed := f.changed
+		f.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+func (f *Fake) add(w *waiter, d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	w.seq = f.seq
+	w.at = f.now.Add(d)
+	f.waiters = append(f.waiters, w)
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *Fake) remove(w *waiter) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, o := range f.waiters {
+		if o == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	f *Fake
+	w *waiter
+}
+
+func (t fakeTimer) Stop() bool { return t.f.remove(t.w) }
+
+type fakeTicker struct {
+	f *Fake
+	w *waiter
+}
+
+func (t fakeTicker) C() <-chan time.Time { return t.w.ch }
+func (t fakeTicker) Stop()               { t.f.remove(t.w) }
@@ -0,0 +1,103 @@
+// Package correlation carries a per-request correlation ID from the HTTP and
+// gRPC entry points through machine transitions into events and log lines.
+package correlation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// Header is the HTTP (and NATS message) header carrying the ID.
+	Header = "X-Request-ID"
+	// MetadataKey is the gRPC metadata key carrying the ID.
+	MetadataKey = "x-request-id"
+	// altMetadataKey is accepted from callers that use the correlation name.
+	altMetadataKey = "x-correlation-id"
+)
+
+type ctxKey struct{}
+
+func New() string {
+	return uuid.NewString()
+}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the correlation ID in ctx, or "" if there is none.
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Ensure returns ctx carrying a correlation ID, generating one if needed.
+func Ensure(ctx context.Context) (context.Context, string) {
+	if id := FromContext(ctx); id != "" {
+		return ctx, id
+	}
+	id := New()
+	return NewContext(ctx, id), id
+}
+
+// Detach returns a background context carrying only the correlation ID of ctx,
+// for work that outlives the request such as background transitions.
+func Detach(ctx context.Context) context.Context {
+	if id := FromContext(ctx); id != "" {
+		return NewContext(context.Background(), id)
+	}
+	return context.Background()
+}
+
+// Logf logs with the correlation ID of ctx as a prefix when one is present.
+func Logf(ctx context.Context, format string, args ...interface{}) {
+	if id := FromContext(ctx); id != "" {
+		log.Printf("[rid=%s] %s", id, fmt.Sprintf(format, args...))
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// Middleware reads X-Request-ID from the request, generating one if missing,
+// stores it in the request context and echoes it on the response.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if id == "" {
+			id = New()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
+
+// UnaryServerInterceptor does the same for gRPC using the x-request-id (or
+// x-correlation-id) metadata key, returning the ID in the response header.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var id string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			for _, key := range []string{MetadataKey, altMetadataKey} {
+				if v := md.Get(key); len(v) > 0 && v[0] != "" {
+					id = v[0]
+					break
+				}
+			}
+		}
+		if id == "" {
+			id = New()
+		}
+		_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, id))
+		return handler(NewContext(ctx, id), req)
+	}
+}
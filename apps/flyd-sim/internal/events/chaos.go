@@ -0,0 +1,46 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/clock"
+	natsclient "github.com/devghori1264/aerophoenix/flyd-sim/internal/nats"
+)
+
+// ChaosNotifier returns a chaos.Engine notifier that publishes chaos.applied,
+// chaos.healed and scenario events to sink, so consumers can annotate their
+// timelines with the faults that were active. Events are stamped with clk.
+func ChaosNotifier(sink EventSink, clk clock.Clock) func(chaos.Event) {
+	return func(ev chaos.Event) {
+		if sink == nil {
+			return
+		}
+		payload := map[string]interface{}{
+			"event": ev.Type,
+			"time":  clk.Now().Unix(),
+		}
+		if ev.Fault.ID != "" {
+			payload["fault"] = ev.Fault
+		}
+		if ev.Reason != "" {
+			payload["reason"] = ev.Reason
+		}
+		if ev.Scenario != "" {
+			payload["scenario"] = ev.Scenario
+			payload["loop"] = ev.Loop
+			payload["step"] = ev.Step
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return
+		}
+		subject := natsclient.ChaosEventSubject(ev.Fault.Scope.Region, strings.TrimPrefix(ev.Type, "chaos."))
+		if err := sink.Publish(context.Background(), subject, data); err != nil {
+			log.Printf("[chaos] publish %s failed: %v", subject, err)
+		}
+	}
+}
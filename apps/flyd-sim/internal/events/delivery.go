@@ -0,0 +1,136 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/clock"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/correlation"
+	natsclient "github.com/devghori1264/aerophoenix/flyd-sim/internal/nats"
+)
+
+// reorderWindow bounds how long a reordered event waits for another event to
+// overtake it before it is sent anyway.
+const reorderWindow = time.Second
+
+// DeliveryChaosSink wraps a sink and applies the chaos engine's delivery
+// faults to machine events, so consumers can be tested against lost,
+// duplicated, late and out-of-order events. Other subjects pass through.
+// Delays and the reorder window run on the engine's clock.
+type DeliveryChaosSink struct {
+	inner EventSink
+	chaos *chaos.Engine
+	clock clock.Clock
+
+	mu     sync.Mutex
+	held   *heldEvent
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type heldEvent struct {
+	ctx     context.Context
+	subject string
+	data    []byte
+	timer   clock.Timer
+}
+
+func NewDeliveryChaosSink(inner EventSink, e *chaos.Engine) *DeliveryChaosSink {
+	return &DeliveryChaosSink{inner: inner, chaos: e, clock: e.Clock()}
+}
+
+func (s *DeliveryChaosSink) Publish(ctx context.Context, subject string, data []byte) error {
+	region, id, eventType, ok := natsclient.ParseMachineSubject(subject)
+	if !ok {
+		return s.inner.Publish(ctx, subject, data)
+	}
+	d := s.chaos.Delivery(chaos.Target{Region: region, MachineID: id, Method: eventType})
+	if d.Drop {
+		correlation.Logf(ctx, "[delivery] dropped %s", subject)
+		return nil
+	}
+	copies := 1
+	if d.Duplicate {
+		copies = 2
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return s.inner.Publish(ctx, subject, data)
+	}
+	// An event already held back goes out after this one, which is what
+	// puts the two out of order.
+	prev := s.held
+	s.held = nil
+	if prev != nil {
+		prev.timer.Stop()
+	}
+	if d.Reorder {
+		ev := &heldEvent{ctx: correlation.Detach(ctx), subject: subject, data: data}
+		ev.timer = s.clock.AfterFunc(reorderWindow, func() { s.release(ev) })
+		s.held = ev
+	}
+	if d.Delay > 0 && !d.Reorder {
+		s.wg.Add(1)
+	}
+	s.mu.Unlock()
+
+	var err error
+	switch {
+	case d.Reorder:
+		// Duplicates of a held event are dropped with it; one late copy
+		// is disorder enough.
+	case d.Delay > 0:
+		go func(ctx context.Context) {
+			defer s.wg.Done()
+			s.clock.Sleep(ctx, d.Delay)
+			for i := 0; i < copies; i++ {
+				s.send(ctx, subject, data)
+			}
+		}(correlation.Detach(ctx))
+	default:
+		for i := 0; i < copies && err == nil; i++ {
+			err = s.inner.Publish(ctx, subject, data)
+		}
+	}
+	if prev != nil {
+		s.send(prev.ctx, prev.subject, prev.data)
+	}
+	return err
+}
+
+// release sends ev if it is still the held event once its window closes.
+func (s *DeliveryChaosSink) release(ev *heldEvent) {
+	s.mu.Lock()
+	if s.held != ev {
+		s.mu.Unlock()
+		return
+	}
+	s.held = nil
+	s.mu.Unlock()
+	s.send(ev.ctx, ev.subject, ev.data)
+}
+
+func (s *DeliveryChaosSink) send(ctx context.Context, subject string, data []byte) {
+	if err := s.inner.Publish(ctx, subject, data); err != nil {
+		correlation.Logf(ctx, "[delivery] publish %s failed: %v", subject, err)
+	}
+}
+
+// Close sends any held and delayed events, then closes the wrapped sink.
+func (s *DeliveryChaosSink) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	held := s.held
+	s.held = nil
+	s.mu.Unlock()
+	if held != nil {
+		held.timer.Stop()
+		s.send(held.ctx, held.subject, held.data)
+	}
+	s.wg.Wait()
+	return s.inner.Close()
+}
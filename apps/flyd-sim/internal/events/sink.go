@@ -0,0 +1,42 @@
+package events
+
+import (
+	"context"
+	"errors"
+
+	natsclient "github.com/devghori1264/aerophoenix/flyd-sim/internal/nats"
+)
+
+// EventSink is a destination for machine and chaos events. Subjects follow the
+// NATS hierarchy (see natsclient.MachineSubject) even for sinks that are not
+// NATS, so every destination can be filtered the same way.
+type EventSink interface {
+	Publish(ctx context.Context, subject string, data []byte) error
+	Close() error
+}
+
+var _ EventSink = (*natsclient.Publisher)(nil)
+
+// Fanout publishes every event to all of its sinks. A failing sink does not
+// stop delivery to the others; their errors are joined.
+type Fanout []EventSink
+
+func (f Fanout) Publish(ctx context.Context, subject string, data []byte) error {
+	var errs []error
+	for _, s := range f {
+		if err := s.Publish(ctx, subject, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f Fanout) Close() error {
+	var errs []error
+	for _, s := range f {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
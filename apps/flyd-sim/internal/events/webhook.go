@@ -0,0 +1,252 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/clock"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/correlation"
+)
+
+var (
+	ErrSinkClosed   = errors.New("sink closed")
+	ErrQueueFull    = errors.New("webhook queue full")
+	ErrDrainTimeout = errors.New("webhook drain timed out")
+)
+
+const (
+	SignatureHeader = "X-Flyd-Signature"
+	TimestampHeader = "X-Flyd-Timestamp"
+	SubjectHeader   = "X-Flyd-Subject"
+)
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<subject>.<body>" keyed by
+// secret. Receivers recompute it from the timestamp and subject headers to
+// verify a delivery and reject replays outside their tolerance window.
+func Sign(secret string, timestamp int64, subject string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write([]byte(subject))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type webhookEvent struct {
+	subject   string
+	data      []byte
+	requestID string
+}
+
+// WebhookSink POSTs events to an HTTP endpoint. Deliveries are queued and sent
+// by a background worker so slow receivers never block the caller; failed
+// deliveries are retried with exponential backoff.
+type WebhookSink struct {
+	url          string
+	secret       string
+	client       *http.Client
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	drainTimeout time.Duration
+	clock        clock.Clock
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan webhookEvent
+	// stopping is cancelled by Close to cut retry backoff short; ctx is
+	// cancelled to abort in-flight requests once the drain times out.
+	stopping context.Context
+	stop     context.CancelFunc
+	ctx      context.Context
+	abort    context.CancelFunc
+	done     chan struct{}
+}
+
+type WebhookOption func(*WebhookSink)
+
+// WithMaxAttempts sets how many times a delivery is tried before it is dropped.
+func WithMaxAttempts(n int) WebhookOption {
+	return func(s *WebhookSink) {
+		if n > 0 {
+			s.maxAttempts = n
+		}
+	}
+}
+
+// WithBackoff sets the delay before the first retry; it doubles per attempt up
+// to max.
+func WithBackoff(initial, max time.Duration) WebhookOption {
+	return func(s *WebhookSink) {
+		s.backoff = initial
+		s.maxBackoff = max
+	}
+}
+
+// WithDrainTimeout bounds how long Close waits for queued deliveries.
+func WithDrainTimeout(d time.Duration) WebhookOption {
+	return func(s *WebhookSink) {
+		if d > 0 {
+			s.drainTimeout = d
+		}
+	}
+}
+
+// WithWebhookClock sets the time source for signatures, backoff and the
+// drain timeout; it defaults to the wall clock.
+func WithWebhookClock(c clock.Clock) WebhookOption {
+	return func(s *WebhookSink) { s.clock = c }
+}
+
+func WithHTTPClient(c *http.Client) WebhookOption {
+	return func(s *WebhookSink) { s.client = c }
+}
+
+func NewWebhookSink(url, secret string, opts ...WebhookOption) *WebhookSink {
+	s := &WebhookSink{
+		url:          url,
+		secret:       secret,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		maxAttempts:  5,
+		backoff:      500 * time.Millisecond,
+		maxBackoff:   30 * time.Second,
+		drainTimeout: 5 * time.Second,
+		clock:        clock.Real(),
+		queue:        make(chan webhookEvent, 1024),
+		done:         make(chan struct{}),
+	}
+	s.stopping, s.stop = context.WithCancel(context.Background())
+	s.ctx, s.abort = context.WithCancel(context.Background())
+	for _, o := range opts {
+		o(s)
+	}
+	go s.run()
+	return s
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, subject string, data []byte) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	ev := webhookEvent{
+		subject:   subject,
+		data:      append([]byte(nil), data...),
+		requestID: correlation.FromContext(ctx),
+	}
+	select {
+	case s.queue <- ev:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting events and gives queued deliveries one more attempt
+// each, without retries. Whatever is still queued or in flight after the
+// drain timeout is dropped.
+func (s *WebhookSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.stop()
+	s.mu.Unlock()
+
+	t := s.clock.AfterFunc(s.drainTimeout, s.abort)
+	<-s.done
+	timedOut := !t.Stop()
+	s.abort()
+	if timedOut {
+		return ErrDrainTimeout
+	}
+	return nil
+}
+
+func (s *WebhookSink) run() {
+	defer close(s.done)
+	dropped := 0
+	for ev := range s.queue {
+		if s.ctx.Err() != nil {
+			dropped++
+			continue
+		}
+		s.deliver(ev)
+	}
+	if dropped > 0 {
+		log.Printf("[webhook] dropped %d queued events on close", dropped)
+	}
+}
+
+func (s *WebhookSink) deliver(ev webhookEvent) {
+	delay := s.backoff
+	var err error
+	for attempt := 1; attempt <= s.maxAttempts; attempt++ {
+		var retry bool
+		retry, err = s.post(ev)
+		if err == nil || !retry {
+			break
+		}
+		if attempt < s.maxAttempts {
+			if s.clock.Sleep(s.stopping, delay) != nil {
+				log.Printf("[webhook] dropping %s event on close: %v", ev.subject, err)
+				return
+			}
+			delay *= 2
+			if delay > s.maxBackoff {
+				delay = s.maxBackoff
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("[webhook] dropping %s event: %v", ev.subject, err)
+	}
+}
+
+// post sends a single delivery attempt and reports whether a failure is worth
+// retrying: transport errors, 429 and 5xx are; other statuses are not.
+func (s *WebhookSink) post(ev webhookEvent) (bool, error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url, bytes.NewReader(ev.data))
+	if err != nil {
+		return false, err
+	}
+	ts := s.clock.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SubjectHeader, ev.subject)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+	if ev.requestID != "" {
+		req.Header.Set(correlation.Header, ev.requestID)
+	}
+	if s.secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(s.secret, ts, ev.subject, ev.data))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,79 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/clock"
+)
+
+// record is one NDJSON line written by WriterSink.
+type record struct {
+	Subject string          `json:"subject"`
+	Time    time.Time       `json:"time"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// WriterSink writes each event as a single NDJSON line.
+type WriterSink struct {
+	mu    sync.Mutex
+	w     io.Writer
+	enc   *json.Encoder
+	clock clock.Clock
+}
+
+type WriterOption func(*WriterSink)
+
+// WithClock sets the time source used to stamp records; it defaults to the
+// wall clock.
+func WithClock(c clock.Clock) WriterOption {
+	return func(s *WriterSink) { s.clock = c }
+}
+
+func NewWriterSink(w io.Writer, opts ...WriterOption) *WriterSink {
+	s := &WriterSink{w: w, enc: json.NewEncoder(w), clock: clock.Real()}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// NewStdoutSink writes events to standard output.
+func NewStdoutSink(opts ...WriterOption) *WriterSink {
+	return NewWriterSink(os.Stdout, opts...)
+}
+
+// NewFileSink appends events to the NDJSON file at path, creating it if needed.
+func NewFileSink(path string, opts ...WriterOption) (*WriterSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterSink(f, opts...), nil
+}
+
+func (s *WriterSink) Publish(ctx context.Context, subject string, data []byte) error {
+	rec := record{Subject: subject, Time: s.clock.Now().UTC(), Data: data}
+	if !json.Valid(data) {
+		quoted, _ := json.Marshal(string(data))
+		rec.Data = quoted
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(rec)
+}
+
+// Close closes the underlying writer unless it is stdout or stderr.
+func (s *WriterSink) Close() error {
+	if s.w == os.Stdout || s.w == os.Stderr {
+		return nil
+	}
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package heartbeat
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/clock"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/events"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/storage"
+)
+
+// Subject is where every flyd-sim host publishes its heartbeat.
+const Subject = "flyd.heartbeat"
+
+// Heartbeat is the periodic liveness and fleet summary published by a host.
+// It is sent even when no machine changes, so consumers can tell an idle
+// host from a dead one.
+type Heartbeat struct {
+	HostID        string         `json:"host_id"`
+	Version       string         `json:"version"`
+	StartedAt     time.Time      `json:"started_at"`
+	UptimeSeconds int64          `json:"uptime_s"`
+	Time          int64          `json:"time"`
+	Machines      int            `json:"machines"`
+	ByRegion      map[string]int `json:"by_region"`
+	ByStatus      map[string]int `json:"by_status"`
+	Chaos         *chaos.State   `json:"chaos,omitempty"`
+}
+
+type Config struct {
+	HostID   string
+	Version  string
+	Interval time.Duration
+	// Clock is the time source; nil means the wall clock.
+	Clock clock.Clock
+}
+
+// Emitter builds heartbeats from the store and publishes them on a timer.
+type Emitter struct {
+	cfg     Config
+	store   storage.Store
+	sink    events.EventSink
+	chaos   *chaos.Engine
+	started time.Time
+}
+
+// NewEmitter returns an Emitter. e, if set, supplies the active chaos state
+// included in every heartbeat, and machines it partitions off are counted as
+// unreachable, as callers see them.
+func NewEmitter(cfg Config, store storage.Store, sink events.EventSink, e *chaos.Engine) *Emitter {
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real()
+	}
+	return &Emitter{
+		cfg:     cfg,
+		store:   store,
+		sink:    sink,
+		chaos:   e,
+		started: cfg.Clock.Now().UTC(),
+	}
+}
+
+// Run publishes a heartbeat immediately and then every interval until ctx is
+// cancelled.
+func (e *Emitter) Run(ctx context.Context) {
+	if e.sink == nil || e.cfg.Interval <= 0 {
+		return
+	}
+	ticker := e.cfg.Clock.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		e.publish(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// Snapshot builds the current heartbeat without publishing it.
+func (e *Emitter) Snapshot(ctx context.Context) (*Heartbeat, error) {
+	machines, err := e.store.ListMachines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := e.cfg.Clock.Now().UTC()
+	hb := &Heartbeat{
+		HostID:        e.cfg.HostID,
+		Version:       e.cfg.Version,
+		StartedAt:     e.started,
+		UptimeSeconds: int64(now.Sub(e.started).Seconds()),
+		Time:          now.Unix(),
+		ByRegion:      map[string]int{},
+		ByStatus:      map[string]int{},
+	}
+	for _, m := range machines {
+		// Destroyed machines are kept as records but are no longer part of
+		// the fleet.
+		if m.Status == models.StatusTerminated {
+			continue
+		}
+		st := m.Status
+		if e.chaos != nil && e.chaos.Partitioned(chaos.Target{Region: m.Region, MachineID: m.ID}) {
+			st = models.StatusUnreachable
+		}
+		hb.Machines++
+		hb.ByRegion[m.Region]++
+		hb.ByStatus[st]++
+	}
+	if e.chaos != nil {
+		state := e.chaos.State()
+		hb.Chaos = &state
+	}
+	return hb, nil
+}
+
+func (e *Emitter) publish(ctx context.Context) {
+	hb, err := e.Snapshot(ctx)
+	if err != nil {
+		log.Printf("[heartbeat] snapshot failed: %v", err)
+		return
+	}
+	data, err := json.Marshal(hb)
+	if err != nil {
+		return
+	}
+	if err := e.sink.Publish(ctx, Subject, data); err != nil {
+		log.Printf("[heartbeat] publish failed: %v", err)
+	}
+}
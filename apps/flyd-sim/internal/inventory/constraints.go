@@ -0,0 +1,177 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Request is what a machine asks of the scheduler: the resources it
+// reserves, the labels other machines' anti-affinity is matched against and
+// its own placement constraints.
+type Request struct {
+	Resources
+	Labels map[string]string
+	// Host pins the machine to one host.
+	Host string
+	// AntiAffinity keeps the machine off hosts running a machine whose
+	// labels include all of these. It is enforced both ways: a machine is
+	// also kept off hosts whose machines' anti-affinity matches its labels.
+	AntiAffinity map[string]string
+	// SpreadBy is a label key. Machines sharing its value go to the zone
+	// holding the fewest of them, as far as capacity allows.
+	SpreadBy string
+	// Priority ranks the machine for preemption: it may evict machines of
+	// a lower priority.
+	Priority int
+}
+
+// Constraint names reported in a Violation.
+const (
+	ConstraintHost         = "host"
+	ConstraintAntiAffinity = "anti_affinity"
+	ConstraintCapacity     = "capacity"
+	ConstraintState        = "state"
+)
+
+// Violation is why one host was ruled out for a machine.
+type Violation struct {
+	Constraint string `json:"constraint"`
+	Host       string `json:"host"`
+	Detail     string `json:"detail"`
+}
+
+// UnsatisfiableError is returned when a machine's host pin or anti-affinity
+// rules out every host that could otherwise take it. Violations lists why
+// each host in the region was ruled out.
+type UnsatisfiableError struct {
+	Region     string
+	Violations []Violation
+}
+
+func (e *UnsatisfiableError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, fmt.Sprintf("%s: %s", v.Host, v.Detail))
+	}
+	return fmt.Sprintf("placement constraints unsatisfiable in region %s: %s", e.Region, strings.Join(parts, "; "))
+}
+
+// feasibleLocked returns the hosts in region that can take req, ignoring
+// machineID's own placement, and the violations that ruled out the rest.
+// Violations are only returned when a constraint, not just capacity, is to
+// blame.
+func (inv *Inventory) feasibleLocked(machineID, region string, req Request) ([]*Host, []Violation) {
+	if req.Host != "" {
+		if h, ok := inv.hosts[req.Host]; !ok || h.Region != region {
+			return nil, []Violation{{Constraint: ConstraintHost, Host: req.Host, Detail: "pinned host is not in region " + region}}
+		}
+	}
+	others := make(map[string][]string)
+	for id, p := range inv.placed {
+		if id != machineID {
+			others[p.host] = append(others[p.host], id)
+		}
+	}
+
+	var out []*Host
+	var violations []Violation
+	constrained := false
+	for _, h := range inv.regions[region] {
+		if req.Host != "" && h.ID != req.Host {
+			continue
+		}
+		v := Violation{Host: h.ID}
+		switch {
+		case h.State != HostReady:
+			v.Constraint, v.Detail = ConstraintState, "host is "+string(h.State)
+		case !h.Free().fits(req.Resources):
+			v.Constraint, v.Detail = ConstraintCapacity, fmt.Sprintf("%d cpus and %d MB free", h.Free().CPUs, h.Free().MemoryMB)
+		default:
+			if id := inv.conflictLocked(req, others[h.ID]); id != "" {
+				v.Constraint, v.Detail = ConstraintAntiAffinity, "conflicts with machine "+id
+			}
+		}
+		if v.Constraint == "" {
+			out = append(out, h)
+			continue
+		}
+		if req.Host != "" || v.Constraint == ConstraintAntiAffinity {
+			constrained = true
+		}
+		violations = append(violations, v)
+	}
+	if len(out) > 0 {
+		return inv.spreadLocked(out, machineID, region, req), nil
+	}
+	if !constrained {
+		return nil, nil
+	}
+	if req.Host != "" {
+		for i := range violations {
+			violations[i].Constraint = ConstraintHost
+			violations[i].Detail = "pinned host: " + violations[i].Detail
+		}
+	}
+	return nil, violations
+}
+
+// conflictLocked returns the first of ids whose labels match req's
+// anti-affinity or whose anti-affinity matches req's labels.
+func (inv *Inventory) conflictLocked(req Request, ids []string) string {
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	for _, id := range sorted {
+		p := inv.placed[id]
+		if matches(p.req.Labels, req.AntiAffinity) || matches(req.Labels, p.req.AntiAffinity) {
+			return id
+		}
+	}
+	return ""
+}
+
+// spreadLocked narrows hosts to those in the zones holding the fewest
+// machines that share req's SpreadBy label value.
+func (inv *Inventory) spreadLocked(hosts []*Host, machineID, region string, req Request) []*Host {
+	value, ok := req.Labels[req.SpreadBy]
+	if req.SpreadBy == "" || !ok {
+		return hosts
+	}
+	zoneOf := make(map[string]string, len(inv.regions[region]))
+	for _, h := range inv.regions[region] {
+		zoneOf[h.ID] = h.Zone
+	}
+	count := make(map[string]int)
+	for id, p := range inv.placed {
+		if zone, inRegion := zoneOf[p.host]; inRegion && id != machineID && p.req.Labels[req.SpreadBy] == value {
+			count[zone]++
+		}
+	}
+	least := -1
+	for _, h := range hosts {
+		if n := count[h.Zone]; least < 0 || n < least {
+			least = n
+		}
+	}
+	var out []*Host
+	for _, h := range hosts {
+		if count[h.Zone] == least {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// matches reports whether labels include every pair in selector. An empty
+// selector matches nothing.
+func matches(labels, selector map[string]string) bool {
+	if len(selector) == 0 {
+		return false
+	}
+	for k, v := range selector {
+		if got, ok := labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,381 @@
+// Package inventory models the region catalog and the simulated hosts
+// machines run on, and places machines onto them so capacity exhaustion can
+// be simulated per region.
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// ErrNoCapacity is returned when no host in a region can fit a machine.
+	ErrNoCapacity = errors.New("insufficient capacity")
+	// ErrUnknownHost is returned for host IDs that are not configured.
+	ErrUnknownHost = errors.New("unknown host")
+)
+
+// HostState is whether a host accepts new machines.
+type HostState string
+
+const (
+	HostReady HostState = "ready"
+	// HostCordoned keeps its machines but takes no new ones.
+	HostCordoned HostState = "cordoned"
+	// HostFailed has lost its machines and takes no new ones.
+	HostFailed HostState = "failed"
+)
+
+// Strategy picks a host among those that fit a machine.
+type Strategy string
+
+const (
+	// StrategyBinpack fills the fullest host first, keeping others free for
+	// large machines.
+	StrategyBinpack Strategy = "binpack"
+	// StrategySpread places on the emptiest host, spreading load.
+	StrategySpread Strategy = "spread"
+)
+
+// Resources is an amount of CPU and memory, either a host's capacity or what
+// a machine reserves.
+type Resources struct {
+	CPUs     int `json:"cpus" yaml:"cpus"`
+	MemoryMB int `json:"memory_mb" yaml:"memory_mb"`
+}
+
+func (r Resources) add(o Resources) Resources {
+	return Resources{CPUs: r.CPUs + o.CPUs, MemoryMB: r.MemoryMB + o.MemoryMB}
+}
+
+func (r Resources) sub(o Resources) Resources {
+	return Resources{CPUs: r.CPUs - o.CPUs, MemoryMB: r.MemoryMB - o.MemoryMB}
+}
+
+func (r Resources) fits(o Resources) bool {
+	return o.CPUs <= r.CPUs && o.MemoryMB <= r.MemoryMB
+}
+
+// Host is a simulated host. State, Used and Machines are filled in on
+// snapshots. Hosts without a zone share the region's unnamed zone.
+type Host struct {
+	ID       string    `json:"id" yaml:"id"`
+	Region   string    `json:"region" yaml:"-"`
+	Zone     string    `json:"zone,omitempty" yaml:"zone"`
+	Capacity Resources `json:"capacity" yaml:",inline"`
+	State    HostState `json:"state" yaml:"-"`
+	Used     Resources `json:"used" yaml:"-"`
+	Machines int       `json:"machines" yaml:"-"`
+}
+
+// Free is the capacity left on h.
+func (h Host) Free() Resources { return h.Capacity.sub(h.Used) }
+
+// Region is a region catalog entry and the hosts it contains.
+type Region struct {
+	Code      string  `json:"code" yaml:"code"`
+	Name      string  `json:"name" yaml:"name"`
+	Latitude  float64 `json:"latitude" yaml:"latitude"`
+	Longitude float64 `json:"longitude" yaml:"longitude"`
+	Hosts     []Host  `json:"-" yaml:"hosts"`
+}
+
+// RegionUsage is a snapshot of a region's hosts and how full they are.
+type RegionUsage struct {
+	Region
+	HostCount int       `json:"hosts"`
+	Machines  int       `json:"machines"`
+	Capacity  Resources `json:"capacity"`
+	Used      Resources `json:"used"`
+}
+
+// Config is the inventory file, e.g.
+//
+//	strategy: spread
+//	regions:
+//	  - code: iad
+//	    name: Ashburn, Virginia (US)
+//	    latitude: 39.03
+//	    longitude: -77.49
+//	    hosts:
+//	      - {id: iad-1, zone: iad-a, cpus: 16, memory_mb: 32768}
+//	rtt_ms:
+//	  iad: {ams: 82}
+//
+// RTTs not listed under rtt_ms are derived from the region coordinates.
+type Config struct {
+	Strategy Strategy                      `yaml:"strategy"`
+	Regions  []Region                      `yaml:"regions"`
+	RTTMs    map[string]map[string]float64 `yaml:"rtt_ms"`
+}
+
+// LoadConfig reads an inventory config from a YAML file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+type placement struct {
+	host string
+	req  Request
+	// evicting is set once the machine is chosen for preemption, so it is
+	// not chosen twice.
+	evicting bool
+}
+
+// Inventory tracks host capacity and which host each machine is placed on.
+// It is safe for concurrent use.
+type Inventory struct {
+	mu       sync.Mutex
+	strategy Strategy
+	hosts    map[string]*Host
+	regions  map[string][]*Host
+	catalog  []Region
+	// rtt is fixed at construction, so it is read without the lock.
+	rtt    map[string]map[string]float64
+	placed map[string]placement
+}
+
+// New validates cfg and returns an empty inventory for it.
+func New(cfg *Config) (*Inventory, error) {
+	inv := &Inventory{
+		strategy: cfg.Strategy,
+		hosts:    make(map[string]*Host),
+		regions:  make(map[string][]*Host),
+		placed:   make(map[string]placement),
+	}
+	switch inv.strategy {
+	case "":
+		inv.strategy = StrategyBinpack
+	case StrategyBinpack, StrategySpread:
+	default:
+		return nil, fmt.Errorf("unknown placement strategy %q", cfg.Strategy)
+	}
+	for _, r := range cfg.Regions {
+		if r.Code == "" {
+			return nil, errors.New("region code required")
+		}
+		if inv.HasRegion(r.Code) {
+			return nil, fmt.Errorf("duplicate region %s", r.Code)
+		}
+		entry := r
+		entry.Hosts = nil
+		if entry.Name == "" {
+			entry.Name = r.Code
+		}
+		inv.catalog = append(inv.catalog, entry)
+		for _, h := range r.Hosts {
+			if h.ID == "" {
+				return nil, fmt.Errorf("region %s: host id required", r.Code)
+			}
+			if _, dup := inv.hosts[h.ID]; dup {
+				return nil, fmt.Errorf("duplicate host %s", h.ID)
+			}
+			if h.Capacity.CPUs <= 0 || h.Capacity.MemoryMB <= 0 {
+				return nil, fmt.Errorf("host %s: cpus and memory_mb must be positive", h.ID)
+			}
+			host := &Host{ID: h.ID, Region: r.Code, Zone: h.Zone, Capacity: h.Capacity, State: HostReady}
+			inv.hosts[h.ID] = host
+			inv.regions[r.Code] = append(inv.regions[r.Code], host)
+		}
+	}
+	if err := inv.buildRTT(cfg.RTTMs); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// Place reserves req for machineID on a host in region chosen by the
+// inventory's strategy and returns the host ID. It fails with ErrNoCapacity
+// when no host has room, or an *UnsatisfiableError when hosts have room but
+// the request's constraints rule them out.
+func (inv *Inventory) Place(machineID, region string, req Request) (string, error) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if p, ok := inv.placed[machineID]; ok {
+		return p.host, nil
+	}
+	best, err := inv.pickLocked(machineID, region, req)
+	if err != nil {
+		return "", err
+	}
+	inv.reserveLocked(machineID, best, req)
+	return best.ID, nil
+}
+
+// Move places machineID on a host in region and then frees its old host. On
+// failure the old placement is kept.
+func (inv *Inventory) Move(machineID, region string, req Request) (string, error) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	best, err := inv.pickLocked(machineID, region, req)
+	if err != nil {
+		return "", err
+	}
+	inv.releaseLocked(machineID)
+	inv.reserveLocked(machineID, best, req)
+	return best.ID, nil
+}
+
+func (inv *Inventory) pickLocked(machineID, region string, req Request) (*Host, error) {
+	candidates, violations := inv.feasibleLocked(machineID, region, req)
+	if len(candidates) == 0 {
+		if len(violations) > 0 {
+			return nil, &UnsatisfiableError{Region: region, Violations: violations}
+		}
+		return nil, fmt.Errorf("%w in region %s for %d cpus and %d MB", ErrNoCapacity, region, req.CPUs, req.MemoryMB)
+	}
+	var best *Host
+	var bestScore float64
+	for _, h := range candidates {
+		score := freeShare(h, req.Resources)
+		if inv.strategy == StrategySpread {
+			score = -score
+		}
+		if best == nil || score < bestScore {
+			best, bestScore = h, score
+		}
+	}
+	return best, nil
+}
+
+// freeShare is the fraction of h's CPU and memory left after placing res,
+// averaged; binpack minimises it and spread maximises it.
+func freeShare(h *Host, res Resources) float64 {
+	free := h.Free().sub(res)
+	return (float64(free.CPUs)/float64(h.Capacity.CPUs) + float64(free.MemoryMB)/float64(h.Capacity.MemoryMB)) / 2
+}
+
+// Restore records an existing placement, e.g. for machines loaded from the
+// store at startup. It does not check capacity: the machine is already there.
+func (inv *Inventory) Restore(machineID, hostID string, req Request) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	h, ok := inv.hosts[hostID]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownHost, hostID)
+	}
+	if _, ok := inv.placed[machineID]; !ok {
+		inv.reserveLocked(machineID, h, req)
+	}
+	return nil
+}
+
+// Release frees the resources held by machineID, if any.
+func (inv *Inventory) Release(machineID string) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.releaseLocked(machineID)
+}
+
+func (inv *Inventory) releaseLocked(machineID string) {
+	p, ok := inv.placed[machineID]
+	if !ok {
+		return
+	}
+	delete(inv.placed, machineID)
+	if h, ok := inv.hosts[p.host]; ok {
+		h.Used = h.Used.sub(p.req.Resources)
+		h.Machines--
+	}
+}
+
+func (inv *Inventory) reserveLocked(machineID string, h *Host, req Request) {
+	h.Used = h.Used.add(req.Resources)
+	h.Machines++
+	inv.placed[machineID] = placement{host: h.ID, req: req}
+}
+
+// Hosts returns a snapshot of every host, ordered by region and ID.
+func (inv *Inventory) Hosts() []Host {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	out := make([]Host, 0, len(inv.hosts))
+	for _, h := range inv.hosts {
+		out = append(out, *h)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Region != out[j].Region {
+			return out[i].Region < out[j].Region
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// HasRegion reports whether code is in the region catalog.
+func (inv *Inventory) HasRegion(code string) bool {
+	for _, r := range inv.catalog {
+		if r.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Regions returns the catalog in config order with each region's usage.
+func (inv *Inventory) Regions() []RegionUsage {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	out := make([]RegionUsage, 0, len(inv.catalog))
+	for _, r := range inv.catalog {
+		u := RegionUsage{Region: r, HostCount: len(inv.regions[r.Code])}
+		for _, h := range inv.regions[r.Code] {
+			u.Machines += h.Machines
+			u.Capacity = u.Capacity.add(h.Capacity)
+			u.Used = u.Used.add(h.Used)
+		}
+		out = append(out, u)
+	}
+	return out
+}
+
+// Host returns a snapshot of one host.
+func (inv *Inventory) Host(id string) (Host, bool) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	h, ok := inv.hosts[id]
+	if !ok {
+		return Host{}, false
+	}
+	return *h, true
+}
+
+// SetState changes a host's state and returns its snapshot. Machines already
+// on the host stay placed there.
+func (inv *Inventory) SetState(id string, state HostState) (Host, error) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	h, ok := inv.hosts[id]
+	if !ok {
+		return Host{}, fmt.Errorf("%w %s", ErrUnknownHost, id)
+	}
+	h.State = state
+	return *h, nil
+}
+
+// MachinesOn lists the machines placed on a host, sorted by ID.
+func (inv *Inventory) MachinesOn(id string) []string {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	var out []string
+	for machineID, p := range inv.placed {
+		if p.host == id {
+			out = append(out, machineID)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,93 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Victims picks machines to preempt so req fits on one host in region and
+// marks them as being evicted; they keep their capacity until released. Only
+// machines of a lower priority than req are candidates, lowest priority and
+// then largest first. The host needing the fewest evictions wins. It fails
+// with ErrNoCapacity when no host can be freed up.
+func (inv *Inventory) Victims(machineID, region string, req Request) (string, []string, error) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	byHost := make(map[string][]string)
+	for id, p := range inv.placed {
+		if id != machineID {
+			byHost[p.host] = append(byHost[p.host], id)
+		}
+	}
+
+	var bestHost string
+	var best []string
+	for _, h := range inv.regions[region] {
+		if h.State != HostReady || (req.Host != "" && h.ID != req.Host) {
+			continue
+		}
+		victims, ok := inv.victimsOnLocked(h, byHost[h.ID], req)
+		if ok && (best == nil || len(victims) < len(best)) {
+			bestHost, best = h.ID, victims
+		}
+	}
+	if best == nil {
+		return "", nil, fmt.Errorf("%w in region %s for %d cpus and %d MB, even with preemption", ErrNoCapacity, region, req.CPUs, req.MemoryMB)
+	}
+	for _, id := range best {
+		p := inv.placed[id]
+		p.evicting = true
+		inv.placed[id] = p
+	}
+	return bestHost, best, nil
+}
+
+// CancelEviction makes machines chosen by Victims candidates again, e.g. when
+// the preemption is abandoned.
+func (inv *Inventory) CancelEviction(ids ...string) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	for _, id := range ids {
+		if p, ok := inv.placed[id]; ok {
+			p.evicting = false
+			inv.placed[id] = p
+		}
+	}
+}
+
+// victimsOnLocked evicts the lowest priority machines on h until req fits
+// and none of the remaining machines conflict with it.
+func (inv *Inventory) victimsOnLocked(h *Host, ids []string, req Request) ([]string, bool) {
+	var candidates, keep []string
+	for _, id := range ids {
+		if p := inv.placed[id]; !p.evicting && p.req.Priority < req.Priority {
+			candidates = append(candidates, id)
+		} else {
+			keep = append(keep, id)
+		}
+	}
+	if inv.conflictLocked(req, keep) != "" {
+		return nil, false
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := inv.placed[candidates[i]].req, inv.placed[candidates[j]].req
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		if a.CPUs != b.CPUs {
+			return a.CPUs > b.CPUs
+		}
+		return candidates[i] < candidates[j]
+	})
+	free := h.Free()
+	n := 0
+	for !free.fits(req.Resources) || inv.conflictLocked(req, candidates[n:]) != "" {
+		if n == len(candidates) {
+			return nil, false
+		}
+		free = free.add(inv.placed[candidates[n]].req.Resources)
+		n++
+	}
+	// A host that fits without evictions needs no preemption.
+	return candidates[:n], n > 0
+}
@@ -0,0 +1,89 @@
+package inventory
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Parameters for RTTs derived from region coordinates: light covers about
+// 200 km per millisecond in fibre, real routes run about 1.5 times the
+// great-circle distance, and every hop adds some fixed overhead.
+const (
+	fibreKmPerMs  = 200.0
+	routeFactor   = 1.5
+	baseRTTMs     = 2.0
+	intraRegionMs = 1.0
+	earthRadiusKm = 6371.0
+)
+
+// buildRTT fills the matrix from coordinates, then applies the configured
+// overrides. An override for a->b also sets b->a unless that is configured
+// separately.
+func (inv *Inventory) buildRTT(overrides map[string]map[string]float64) error {
+	inv.rtt = make(map[string]map[string]float64, len(inv.catalog))
+	for _, a := range inv.catalog {
+		inv.rtt[a.Code] = make(map[string]float64, len(inv.catalog))
+		for _, b := range inv.catalog {
+			inv.rtt[a.Code][b.Code] = derivedRTT(a, b)
+		}
+	}
+	for from, row := range overrides {
+		for to, ms := range row {
+			if !inv.HasRegion(from) || !inv.HasRegion(to) {
+				return fmt.Errorf("rtt_ms %s->%s: unknown region", from, to)
+			}
+			if ms <= 0 {
+				return fmt.Errorf("rtt_ms %s->%s must be positive", from, to)
+			}
+			inv.rtt[from][to] = ms
+			if _, set := overrides[to][from]; !set {
+				inv.rtt[to][from] = ms
+			}
+		}
+	}
+	return nil
+}
+
+func derivedRTT(a, b Region) float64 {
+	if a.Code == b.Code {
+		return intraRegionMs
+	}
+	km := greatCircleKm(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+	return math.Round((baseRTTMs+2*km*routeFactor/fibreKmPerMs)*10) / 10
+}
+
+func greatCircleKm(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// RTT is the round-trip time between two catalog regions, or zero when
+// either is not in the catalog.
+func (inv *Inventory) RTT(from, to string) time.Duration {
+	ms, ok := inv.rtt[from][to]
+	if !ok {
+		return 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// RTTMatrix returns the region codes in catalog order and the RTT in
+// milliseconds between each pair, indexed the same way.
+func (inv *Inventory) RTTMatrix() ([]string, [][]float64) {
+	codes := make([]string, len(inv.catalog))
+	for i, r := range inv.catalog {
+		codes[i] = r.Code
+	}
+	matrix := make([][]float64, len(codes))
+	for i, from := range codes {
+		matrix[i] = make([]float64, len(codes))
+		for j, to := range codes {
+			matrix[i][j] = inv.rtt[from][to]
+		}
+	}
+	return codes, matrix
+}
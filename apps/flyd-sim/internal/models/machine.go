@@ -2,15 +2,157 @@ package models
 
 import "time"
 
+// Machine statuses.
+const (
+	StatusPending  = "pending"
+	StatusStarting = "starting"
+	StatusRunning  = "running"
+	StatusStopped  = "stopped"
+	// StatusCrashed is a machine that exited on its own with a non-zero code.
+	StatusCrashed = "crashed"
+	// StatusExited is a machine that exited on its own with code zero.
+	StatusExited     = "exited"
+	StatusTerminated = "terminated"
+	// StatusUnreachable is reported to callers for a machine whose host is
+	// partitioned off; it is never stored.
+	StatusUnreachable = "unreachable"
+	// StatusLost is a machine whose host failed and that was not
+	// rescheduled.
+	StatusLost = "lost"
+	// StatusSuspended is a machine paused with its memory snapshotted. It
+	// keeps its host and resumes from the snapshot faster than it boots.
+	StatusSuspended = "suspended"
+)
+
+// Restart policies, mirroring Fly machine restart policies.
+const (
+	RestartNo        = "no"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// RestartPolicy decides whether a machine that exits on its own is restarted.
+// MaxRetries bounds on-failure restarts; zero means no limit.
+type RestartPolicy struct {
+	Policy     string `json:"policy"`
+	MaxRetries int    `json:"max_retries,omitempty"`
+}
+
+// ShouldRestart reports whether a machine that exited with exitCode after
+// restarts automatic restarts should be started again.
+func (p RestartPolicy) ShouldRestart(exitCode, restarts int) bool {
+	switch p.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0 && (p.MaxRetries == 0 || restarts < p.MaxRetries)
+	default:
+		return false
+	}
+}
+
+// Status reasons explain why a machine is in its status.
+const (
+	// ReasonWaitingForCapacity is a pending machine queued until a host in
+	// its region has room for it.
+	ReasonWaitingForCapacity = "waiting_for_capacity"
+	// ReasonWaitingForPreemption is a pending machine waiting for the
+	// machines it preempted to stop.
+	ReasonWaitingForPreemption = "waiting_for_preemption"
+	// ReasonPreempted is a machine stopped to make room for a machine of a
+	// higher priority class.
+	ReasonPreempted = "preempted"
+	// ReasonNoCapacity is a machine stopped because it could not be placed
+	// after preempting others.
+	ReasonNoCapacity = "no_capacity"
+)
+
+// Priority classes, lowest first. A machine may preempt machines of a lower
+// class when it does not fit.
+const (
+	PriorityPreemptible = "preemptible"
+	PriorityStandard    = "standard"
+	PriorityCritical    = "critical"
+)
+
+// PriorityRank orders priority classes; it is -1 for an unknown class.
+func PriorityRank(class string) int {
+	switch class {
+	case PriorityPreemptible:
+		return 0
+	case PriorityStandard:
+		return 1
+	case PriorityCritical:
+		return 2
+	default:
+		return -1
+	}
+}
+
+// Default guest size for machines created without one.
+const (
+	DefaultCPUs     = 1
+	DefaultMemoryMB = 256
+)
+
+// Guest is the CPU and memory a machine reserves on its host.
+type Guest struct {
+	CPUs     int `json:"cpus"`
+	MemoryMB int `json:"memory_mb"`
+}
+
+// Snapshot is the simulated memory state of a suspended machine.
+type Snapshot struct {
+	MemoryMB int       `json:"memory_mb"`
+	TakenAt  time.Time `json:"taken_at"`
+}
+
+// Constraints restrict which host a machine may be placed on.
+type Constraints struct {
+	// Host pins the machine to one host.
+	Host string `json:"host,omitempty"`
+	// AntiAffinity keeps the machine off hosts running a machine whose
+	// labels include all of these.
+	AntiAffinity map[string]string `json:"anti_affinity,omitempty"`
+	// SpreadBy is a label key; machines sharing its value are spread evenly
+	// across the region's zones.
+	SpreadBy string `json:"spread_by,omitempty"`
+}
+
 // Machine is the core domain object representing a compute instance or node.
 // Shared between the server and storage layers.
 type Machine struct {
-	ID        string            `json:"id"`
-	Name      string            `json:"name"`
-	Region    string            `json:"region"`
-	Status    string            `json:"status"`
-	Version   int64             `json:"version"`
-	CreatedAt time.Time         `json:"created_at"`
-	UpdatedAt time.Time         `json:"updated_at"`
-	Metadata  map[string]string `json:"metadata,omitempty"`
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Region        string            `json:"region"`
+	Status        string            `json:"status"`
+	StatusReason  string            `json:"status_reason,omitempty"`
+	Version       int64             `json:"version"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	RestartPolicy RestartPolicy     `json:"restart_policy"`
+	ExitCode      int               `json:"exit_code,omitempty"`
+	RestartCount  int               `json:"restart_count,omitempty"`
+	Guest         Guest             `json:"guest"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Constraints   Constraints       `json:"constraints,omitempty"`
+	// PriorityClass decides which machines this one may preempt and the
+	// order machines waiting for capacity are placed in.
+	PriorityClass string `json:"priority_class,omitempty"`
+	// HostID is the simulated host the machine is placed on; empty when no
+	// host inventory is configured.
+	HostID string `json:"host_id,omitempty"`
+	// Snapshot is set while the machine is suspended or resuming.
+	Snapshot *Snapshot `json:"snapshot,omitempty"`
+}
+
+// Lease gives its holder exclusive control of a machine until ExpiresAt:
+// mutating calls must present Nonce while it is held.
+type Lease struct {
+	MachineID   string    `json:"machine_id"`
+	Nonce       string    `json:"nonce"`
+	Owner       string    `json:"owner,omitempty"`
+	Description string    `json:"description,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
 }
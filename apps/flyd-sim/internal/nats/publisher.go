@@ -3,17 +3,93 @@ package natsclient
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/correlation"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 )
 
+// EventsSubject is the root of the machine event hierarchy. Events are
+// published on EventsSubject.<region>.<id>.<type>; the bare root is the
+// legacy flat subject.
+const EventsSubject = "machines.events"
+
+// HostsSubject is the root of the host event hierarchy:
+// hosts.events.<region>.<host>.<type>.
+const HostsSubject = "hosts.events"
+
+// ChaosSubject is the root of the chaos event hierarchy:
+// chaos.events.<region>.<type>, with "_" for faults not scoped to a region.
+const ChaosSubject = "chaos.events"
+
+// MachinesBucket is the JetStream KV bucket holding the latest snapshot of
+// each machine, keyed by machine ID.
+const MachinesBucket = "machines"
+
+// MachineSubject returns the hierarchical subject for a machine event, e.g.
+// machines.events.iad.<id>.started. Characters that are not valid inside a
+// subject token are replaced so a bad region or ID cannot widen the subject.
+func MachineSubject(region, id, eventType string) string {
+	return strings.Join([]string{EventsSubject, subjectToken(region), subjectToken(id), subjectToken(eventType)}, ".")
+}
+
+// ParseMachineSubject splits a subject built by MachineSubject back into its
+// region, machine ID and event type.
+func ParseMachineSubject(subject string) (region, id, eventType string, ok bool) {
+	rest, found := strings.CutPrefix(subject, EventsSubject+".")
+	if !found {
+		return "", "", "", false
+	}
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
+
+// HostSubject returns the subject for a host event such as
+// hosts.events.iad.iad-1.cordoned.
+func HostSubject(region, hostID, eventType string) string {
+	return strings.Join([]string{HostsSubject, subjectToken(region), subjectToken(hostID), subjectToken(eventType)}, ".")
+}
+
+// ChaosEventSubject returns the subject for a chaos event such as
+// chaos.events.iad.applied.
+func ChaosEventSubject(region, eventType string) string {
+	return strings.Join([]string{ChaosSubject, subjectToken(region), subjectToken(eventType)}, ".")
+}
+
+func subjectToken(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
 type Publisher struct {
-	nc  *nats.Conn
-	url string
+	nc          *nats.Conn
+	url         string
+	flatSubject bool
 }
 
-func NewPublisher(url string) (*Publisher, error) {
+// Option configures a Publisher.
+type Option func(*Publisher)
+
+// WithFlatSubject mirrors every hierarchical machine event onto the flat
+// machines.events subject for consumers that have not moved over yet.
+func WithFlatSubject() Option {
+	return func(p *Publisher) { p.flatSubject = true }
+}
+
+func NewPublisher(url string, options ...Option) (*Publisher, error) {
 	opts := []nats.Option{
 		nats.Name("aerophoenix-flyd-sim"),
 		nats.MaxReconnects(-1),
@@ -29,19 +105,61 @@ func NewPublisher(url string) (*Publisher, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Publisher{nc: nc, url: url}, nil
+	p := &Publisher{nc: nc, url: url}
+	for _, o := range options {
+		o(p)
+	}
+	return p, nil
+}
+
+// NewMsg builds the message Publish sends, carrying the correlation ID of
+// ctx in the X-Request-ID header.
+func NewMsg(ctx context.Context, subject string, payload []byte) *nats.Msg {
+	msg := &nats.Msg{Subject: subject, Data: payload}
+	if rid := correlation.FromContext(ctx); rid != "" {
+		msg.Header = nats.Header{}
+		msg.Header.Set(correlation.Header, rid)
+	}
+	return msg
 }
 
 func (p *Publisher) Publish(ctx context.Context, subject string, payload []byte) error {
 	if p.nc == nil || p.nc.IsClosed() {
 		return fmt.Errorf("nats not connected")
 	}
-	return p.nc.Publish(subject, payload)
+	msg := NewMsg(ctx, subject, payload)
+	if err := p.nc.PublishMsg(msg); err != nil {
+		return err
+	}
+	if p.flatSubject && strings.HasPrefix(subject, EventsSubject+".") {
+		flat := &nats.Msg{Subject: EventsSubject, Data: payload, Header: msg.Header}
+		return p.nc.PublishMsg(flat)
+	}
+	return nil
 }
 
-func (p *Publisher) Close() {
-	if p.nc != nil {
-		p.nc.Drain()
-		p.nc.Close()
+// KeyValue creates the named JetStream KV bucket if needed and returns it.
+// Only the latest revision per key is kept; history lives in the event stream.
+func (p *Publisher) KeyValue(ctx context.Context, bucket string) (jetstream.KeyValue, error) {
+	if p.nc == nil || p.nc.IsClosed() {
+		return nil, fmt.Errorf("nats not connected")
+	}
+	js, err := jetstream.New(p.nc)
+	if err != nil {
+		return nil, err
+	}
+	return js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:      bucket,
+		Description: "latest machine snapshots from flyd-sim",
+		History:     1,
+	})
+}
+
+func (p *Publisher) Close() error {
+	if p.nc == nil || p.nc.IsClosed() {
+		return nil
 	}
+	err := p.nc.Drain()
+	p.nc.Close()
+	return err
 }
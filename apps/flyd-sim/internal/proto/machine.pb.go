@@ -101,17 +101,199 @@ func (x *PingResponse) GetMsg() string {
 	return ""
 }
 
+// RestartPolicy decides what flyd-sim does when a machine exits on its own:
+// "no" leaves it down, "on-failure" restarts it after a non-zero exit up to
+// max_retries times (0 means no limit), "always" restarts it after any exit.
+type RestartPolicy struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Policy        string                 `protobuf:"bytes,1,opt,name=policy,proto3" json:"policy,omitempty"`
+	MaxRetries    int32                  `protobuf:"varint,2,opt,name=max_retries,json=maxRetries,proto3" json:"max_retries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestartPolicy) Reset() {
+	*x = RestartPolicy{}
+	mi := &file_machine_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestartPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestartPolicy) ProtoMessage() {}
+
+func (x *RestartPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestartPolicy.ProtoReflect.Descriptor instead.
+func (*RestartPolicy) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RestartPolicy) GetPolicy() string {
+	if x != nil {
+		return x.Policy
+	}
+	return ""
+}
+
+func (x *RestartPolicy) GetMaxRetries() int32 {
+	if x != nil {
+		return x.MaxRetries
+	}
+	return 0
+}
+
+// Guest is the CPU and memory a machine reserves on its host. Unset fields
+// default to 1 CPU and 256 MB.
+type Guest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cpus          int32                  `protobuf:"varint,1,opt,name=cpus,proto3" json:"cpus,omitempty"`
+	MemoryMb      int32                  `protobuf:"varint,2,opt,name=memory_mb,json=memoryMb,proto3" json:"memory_mb,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Guest) Reset() {
+	*x = Guest{}
+	mi := &file_machine_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Guest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Guest) ProtoMessage() {}
+
+func (x *Guest) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Guest.ProtoReflect.Descriptor instead.
+func (*Guest) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Guest) GetCpus() int32 {
+	if x != nil {
+		return x.Cpus
+	}
+	return 0
+}
+
+func (x *Guest) GetMemoryMb() int32 {
+	if x != nil {
+		return x.MemoryMb
+	}
+	return 0
+}
+
+// Constraints restrict which host the scheduler may place a machine on.
+type Constraints struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// host pins the machine to one host.
+	Host string `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	// anti_affinity keeps the machine off hosts running a machine whose labels
+	// include all of these.
+	AntiAffinity map[string]string `protobuf:"bytes,2,rep,name=anti_affinity,json=antiAffinity,proto3" json:"anti_affinity,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// spread_by is a label key; machines sharing its value are spread evenly
+	// across the region's zones.
+	SpreadBy      string `protobuf:"bytes,3,opt,name=spread_by,json=spreadBy,proto3" json:"spread_by,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Constraints) Reset() {
+	*x = Constraints{}
+	mi := &file_machine_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Constraints) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Constraints) ProtoMessage() {}
+
+func (x *Constraints) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Constraints.ProtoReflect.Descriptor instead.
+func (*Constraints) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Constraints) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *Constraints) GetAntiAffinity() map[string]string {
+	if x != nil {
+		return x.AntiAffinity
+	}
+	return nil
+}
+
+func (x *Constraints) GetSpreadBy() string {
+	if x != nil {
+		return x.SpreadBy
+	}
+	return ""
+}
+
 type CreateRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	Region        string                 `protobuf:"bytes,2,opt,name=region,proto3" json:"region,omitempty"`
+	RestartPolicy *RestartPolicy         `protobuf:"bytes,3,opt,name=restart_policy,json=restartPolicy,proto3" json:"restart_policy,omitempty"`
+	Guest         *Guest                 `protobuf:"bytes,4,opt,name=guest,proto3" json:"guest,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,5,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Constraints   *Constraints           `protobuf:"bytes,6,opt,name=constraints,proto3" json:"constraints,omitempty"`
+	// priority_class is preemptible, standard (the default) or critical. A
+	// machine that does not fit may preempt machines of a lower class.
+	PriorityClass string `protobuf:"bytes,7,opt,name=priority_class,json=priorityClass,proto3" json:"priority_class,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *CreateRequest) Reset() {
 	*x = CreateRequest{}
-	mi := &file_machine_proto_msgTypes[2]
+	mi := &file_machine_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -123,7 +305,7 @@ func (x *CreateRequest) String() string {
 func (*CreateRequest) ProtoMessage() {}
 
 func (x *CreateRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_machine_proto_msgTypes[2]
+	mi := &file_machine_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -136,7 +318,7 @@ func (x *CreateRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CreateRequest.ProtoReflect.Descriptor instead.
 func (*CreateRequest) Descriptor() ([]byte, []int) {
-	return file_machine_proto_rawDescGZIP(), []int{2}
+	return file_machine_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *CreateRequest) GetName() string {
@@ -153,17 +335,54 @@ func (x *CreateRequest) GetRegion() string {
 	return ""
 }
 
+func (x *CreateRequest) GetRestartPolicy() *RestartPolicy {
+	if x != nil {
+		return x.RestartPolicy
+	}
+	return nil
+}
+
+func (x *CreateRequest) GetGuest() *Guest {
+	if x != nil {
+		return x.Guest
+	}
+	return nil
+}
+
+func (x *CreateRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *CreateRequest) GetConstraints() *Constraints {
+	if x != nil {
+		return x.Constraints
+	}
+	return nil
+}
+
+func (x *CreateRequest) GetPriorityClass() string {
+	if x != nil {
+		return x.PriorityClass
+	}
+	return ""
+}
+
 type CreateResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Id     string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Status string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	// status_reason is waiting_for_capacity when the machine was queued.
+	StatusReason  string `protobuf:"bytes,3,opt,name=status_reason,json=statusReason,proto3" json:"status_reason,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *CreateResponse) Reset() {
 	*x = CreateResponse{}
-	mi := &file_machine_proto_msgTypes[3]
+	mi := &file_machine_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -175,7 +394,7 @@ func (x *CreateResponse) String() string {
 func (*CreateResponse) ProtoMessage() {}
 
 func (x *CreateResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_machine_proto_msgTypes[3]
+	mi := &file_machine_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -188,7 +407,7 @@ func (x *CreateResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CreateResponse.ProtoReflect.Descriptor instead.
 func (*CreateResponse) Descriptor() ([]byte, []int) {
-	return file_machine_proto_rawDescGZIP(), []int{3}
+	return file_machine_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *CreateResponse) GetId() string {
@@ -205,6 +424,13 @@ func (x *CreateResponse) GetStatus() string {
 	return ""
 }
 
+func (x *CreateResponse) GetStatusReason() string {
+	if x != nil {
+		return x.StatusReason
+	}
+	return ""
+}
+
 type GetRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -214,7 +440,7 @@ type GetRequest struct {
 
 func (x *GetRequest) Reset() {
 	*x = GetRequest{}
-	mi := &file_machine_proto_msgTypes[4]
+	mi := &file_machine_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -226,7 +452,7 @@ func (x *GetRequest) String() string {
 func (*GetRequest) ProtoMessage() {}
 
 func (x *GetRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_machine_proto_msgTypes[4]
+	mi := &file_machine_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -239,7 +465,7 @@ func (x *GetRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetRequest.ProtoReflect.Descriptor instead.
 func (*GetRequest) Descriptor() ([]byte, []int) {
-	return file_machine_proto_rawDescGZIP(), []int{4}
+	return file_machine_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *GetRequest) GetId() string {
@@ -254,13 +480,27 @@ type GetResponse struct {
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
 	Region        string                 `protobuf:"bytes,3,opt,name=region,proto3" json:"region,omitempty"`
+	ExitCode      int32                  `protobuf:"varint,4,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	RestartCount  int32                  `protobuf:"varint,5,opt,name=restart_count,json=restartCount,proto3" json:"restart_count,omitempty"`
+	RestartPolicy *RestartPolicy         `protobuf:"bytes,6,opt,name=restart_policy,json=restartPolicy,proto3" json:"restart_policy,omitempty"`
+	// host_id is the simulated host the machine was placed on, empty when
+	// flyd-sim runs without a host inventory.
+	HostId        string            `protobuf:"bytes,7,opt,name=host_id,json=hostId,proto3" json:"host_id,omitempty"`
+	Guest         *Guest            `protobuf:"bytes,8,opt,name=guest,proto3" json:"guest,omitempty"`
+	Labels        map[string]string `protobuf:"bytes,9,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Constraints   *Constraints      `protobuf:"bytes,10,opt,name=constraints,proto3" json:"constraints,omitempty"`
+	Zone          string            `protobuf:"bytes,11,opt,name=zone,proto3" json:"zone,omitempty"`
+	StatusReason  string            `protobuf:"bytes,12,opt,name=status_reason,json=statusReason,proto3" json:"status_reason,omitempty"`
+	PriorityClass string            `protobuf:"bytes,13,opt,name=priority_class,json=priorityClass,proto3" json:"priority_class,omitempty"`
+	// snapshot is set while the machine is suspended or resuming.
+	Snapshot      *Snapshot `protobuf:"bytes,14,opt,name=snapshot,proto3" json:"snapshot,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *GetResponse) Reset() {
 	*x = GetResponse{}
-	mi := &file_machine_proto_msgTypes[5]
+	mi := &file_machine_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -272,7 +512,7 @@ func (x *GetResponse) String() string {
 func (*GetResponse) ProtoMessage() {}
 
 func (x *GetResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_machine_proto_msgTypes[5]
+	mi := &file_machine_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -285,7 +525,7 @@ func (x *GetResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetResponse.ProtoReflect.Descriptor instead.
 func (*GetResponse) Descriptor() ([]byte, []int) {
-	return file_machine_proto_rawDescGZIP(), []int{5}
+	return file_machine_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *GetResponse) GetId() string {
@@ -309,28 +549,107 @@ func (x *GetResponse) GetRegion() string {
 	return ""
 }
 
-type ActionRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+func (x *GetResponse) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *GetResponse) GetRestartCount() int32 {
+	if x != nil {
+		return x.RestartCount
+	}
+	return 0
+}
+
+func (x *GetResponse) GetRestartPolicy() *RestartPolicy {
+	if x != nil {
+		return x.RestartPolicy
+	}
+	return nil
+}
+
+func (x *GetResponse) GetHostId() string {
+	if x != nil {
+		return x.HostId
+	}
+	return ""
+}
+
+func (x *GetResponse) GetGuest() *Guest {
+	if x != nil {
+		return x.Guest
+	}
+	return nil
+}
+
+func (x *GetResponse) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *GetResponse) GetConstraints() *Constraints {
+	if x != nil {
+		return x.Constraints
+	}
+	return nil
+}
+
+func (x *GetResponse) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+func (x *GetResponse) GetStatusReason() string {
+	if x != nil {
+		return x.StatusReason
+	}
+	return ""
+}
+
+func (x *GetResponse) GetPriorityClass() string {
+	if x != nil {
+		return x.PriorityClass
+	}
+	return ""
+}
+
+func (x *GetResponse) GetSnapshot() *Snapshot {
+	if x != nil {
+		return x.Snapshot
+	}
+	return nil
+}
+
+type Snapshot struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	MemoryMb int32                  `protobuf:"varint,1,opt,name=memory_mb,json=memoryMb,proto3" json:"memory_mb,omitempty"`
+	// taken_at is a Unix timestamp in seconds.
+	TakenAt       int64 `protobuf:"varint,2,opt,name=taken_at,json=takenAt,proto3" json:"taken_at,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ActionRequest) Reset() {
-	*x = ActionRequest{}
-	mi := &file_machine_proto_msgTypes[6]
+func (x *Snapshot) Reset() {
+	*x = Snapshot{}
+	mi := &file_machine_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ActionRequest) String() string {
+func (x *Snapshot) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ActionRequest) ProtoMessage() {}
+func (*Snapshot) ProtoMessage() {}
 
-func (x *ActionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_machine_proto_msgTypes[6]
+func (x *Snapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -341,40 +660,51 @@ func (x *ActionRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ActionRequest.ProtoReflect.Descriptor instead.
-func (*ActionRequest) Descriptor() ([]byte, []int) {
-	return file_machine_proto_rawDescGZIP(), []int{6}
+// Deprecated: Use Snapshot.ProtoReflect.Descriptor instead.
+func (*Snapshot) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{9}
 }
 
-func (x *ActionRequest) GetId() string {
+func (x *Snapshot) GetMemoryMb() int32 {
 	if x != nil {
-		return x.Id
+		return x.MemoryMb
 	}
-	return ""
+	return 0
 }
 
-type ActionResponse struct {
+func (x *Snapshot) GetTakenAt() int64 {
+	if x != nil {
+		return x.TakenAt
+	}
+	return 0
+}
+
+// WaitRequest waits for machine id to reach status. timeout_ms defaults to
+// 30s and is capped at 5 minutes.
+type WaitRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Result        string                 `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	TimeoutMs     int64                  `protobuf:"varint,3,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ActionResponse) Reset() {
-	*x = ActionResponse{}
-	mi := &file_machine_proto_msgTypes[7]
+func (x *WaitRequest) Reset() {
+	*x = WaitRequest{}
+	mi := &file_machine_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ActionResponse) String() string {
+func (x *WaitRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ActionResponse) ProtoMessage() {}
+func (*WaitRequest) ProtoMessage() {}
 
-func (x *ActionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_machine_proto_msgTypes[7]
+func (x *WaitRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -385,50 +715,1033 @@ func (x *ActionResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ActionResponse.ProtoReflect.Descriptor instead.
-func (*ActionResponse) Descriptor() ([]byte, []int) {
-	return file_machine_proto_rawDescGZIP(), []int{7}
+// Deprecated: Use WaitRequest.ProtoReflect.Descriptor instead.
+func (*WaitRequest) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{10}
 }
 
-func (x *ActionResponse) GetResult() string {
+func (x *WaitRequest) GetId() string {
 	if x != nil {
-		return x.Result
+		return x.Id
 	}
 	return ""
 }
 
-var File_machine_proto protoreflect.FileDescriptor
+func (x *WaitRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
 
-const file_machine_proto_rawDesc = "" +
+func (x *WaitRequest) GetTimeoutMs() int64 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+// WaitResponse carries the machine's final snapshot. outcome is "reached",
+// "failed" when the machine crashed, was lost or was destroyed with no restart
+// to come, or "timeout".
+type WaitResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Machine       *GetResponse           `protobuf:"bytes,1,opt,name=machine,proto3" json:"machine,omitempty"`
+	Outcome       string                 `protobuf:"bytes,2,opt,name=outcome,proto3" json:"outcome,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WaitResponse) Reset() {
+	*x = WaitResponse{}
+	mi := &file_machine_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WaitResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WaitResponse) ProtoMessage() {}
+
+func (x *WaitResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WaitResponse.ProtoReflect.Descriptor instead.
+func (*WaitResponse) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *WaitResponse) GetMachine() *GetResponse {
+	if x != nil {
+		return x.Machine
+	}
+	return nil
+}
+
+func (x *WaitResponse) GetOutcome() string {
+	if x != nil {
+		return x.Outcome
+	}
+	return ""
+}
+
+type ActionRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// lease_nonce must match the machine's lease while one is held.
+	LeaseNonce    string `protobuf:"bytes,2,opt,name=lease_nonce,json=leaseNonce,proto3" json:"lease_nonce,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ActionRequest) Reset() {
+	*x = ActionRequest{}
+	mi := &file_machine_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ActionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ActionRequest) ProtoMessage() {}
+
+func (x *ActionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ActionRequest.ProtoReflect.Descriptor instead.
+func (*ActionRequest) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ActionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ActionRequest) GetLeaseNonce() string {
+	if x != nil {
+		return x.LeaseNonce
+	}
+	return ""
+}
+
+type ActionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Result        string                 `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ActionResponse) Reset() {
+	*x = ActionResponse{}
+	mi := &file_machine_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ActionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ActionResponse) ProtoMessage() {}
+
+func (x *ActionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ActionResponse.ProtoReflect.Descriptor instead.
+func (*ActionResponse) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ActionResponse) GetResult() string {
+	if x != nil {
+		return x.Result
+	}
+	return ""
+}
+
+// MigrateRequest moves a machine to target_region. A running machine is
+// restarted there; a stopped one stays stopped.
+type MigrateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	TargetRegion  string                 `protobuf:"bytes,2,opt,name=target_region,json=targetRegion,proto3" json:"target_region,omitempty"`
+	LeaseNonce    string                 `protobuf:"bytes,3,opt,name=lease_nonce,json=leaseNonce,proto3" json:"lease_nonce,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MigrateRequest) Reset() {
+	*x = MigrateRequest{}
+	mi := &file_machine_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MigrateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MigrateRequest) ProtoMessage() {}
+
+func (x *MigrateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MigrateRequest.ProtoReflect.Descriptor instead.
+func (*MigrateRequest) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *MigrateRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *MigrateRequest) GetTargetRegion() string {
+	if x != nil {
+		return x.TargetRegion
+	}
+	return ""
+}
+
+func (x *MigrateRequest) GetLeaseNonce() string {
+	if x != nil {
+		return x.LeaseNonce
+	}
+	return ""
+}
+
+type ListRegionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRegionsRequest) Reset() {
+	*x = ListRegionsRequest{}
+	mi := &file_machine_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRegionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRegionsRequest) ProtoMessage() {}
+
+func (x *ListRegionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRegionsRequest.ProtoReflect.Descriptor instead.
+func (*ListRegionsRequest) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{15}
+}
+
+// Capacity is CPU and memory summed over a region's hosts.
+type Capacity struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cpus          int32                  `protobuf:"varint,1,opt,name=cpus,proto3" json:"cpus,omitempty"`
+	MemoryMb      int32                  `protobuf:"varint,2,opt,name=memory_mb,json=memoryMb,proto3" json:"memory_mb,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Capacity) Reset() {
+	*x = Capacity{}
+	mi := &file_machine_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Capacity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Capacity) ProtoMessage() {}
+
+func (x *Capacity) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Capacity.ProtoReflect.Descriptor instead.
+func (*Capacity) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Capacity) GetCpus() int32 {
+	if x != nil {
+		return x.Cpus
+	}
+	return 0
+}
+
+func (x *Capacity) GetMemoryMb() int32 {
+	if x != nil {
+		return x.MemoryMb
+	}
+	return 0
+}
+
+// Region is a catalog entry with its capacity and live chaos status.
+type Region struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Code        string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Name        string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Latitude    float64                `protobuf:"fixed64,3,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude   float64                `protobuf:"fixed64,4,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	Hosts       int32                  `protobuf:"varint,5,opt,name=hosts,proto3" json:"hosts,omitempty"`
+	Machines    int32                  `protobuf:"varint,6,opt,name=machines,proto3" json:"machines,omitempty"`
+	Capacity    *Capacity              `protobuf:"bytes,7,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	Used        *Capacity              `protobuf:"bytes,8,opt,name=used,proto3" json:"used,omitempty"`
+	Partitioned bool                   `protobuf:"varint,9,opt,name=partitioned,proto3" json:"partitioned,omitempty"`
+	LatencyMs   int32                  `protobuf:"varint,10,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
+	// queued is how many machines are waiting for capacity in the region.
+	Queued        int32 `protobuf:"varint,11,opt,name=queued,proto3" json:"queued,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Region) Reset() {
+	*x = Region{}
+	mi := &file_machine_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Region) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Region) ProtoMessage() {}
+
+func (x *Region) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Region.ProtoReflect.Descriptor instead.
+func (*Region) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Region) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Region) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Region) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *Region) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+func (x *Region) GetHosts() int32 {
+	if x != nil {
+		return x.Hosts
+	}
+	return 0
+}
+
+func (x *Region) GetMachines() int32 {
+	if x != nil {
+		return x.Machines
+	}
+	return 0
+}
+
+func (x *Region) GetCapacity() *Capacity {
+	if x != nil {
+		return x.Capacity
+	}
+	return nil
+}
+
+func (x *Region) GetUsed() *Capacity {
+	if x != nil {
+		return x.Used
+	}
+	return nil
+}
+
+func (x *Region) GetPartitioned() bool {
+	if x != nil {
+		return x.Partitioned
+	}
+	return false
+}
+
+func (x *Region) GetLatencyMs() int32 {
+	if x != nil {
+		return x.LatencyMs
+	}
+	return 0
+}
+
+func (x *Region) GetQueued() int32 {
+	if x != nil {
+		return x.Queued
+	}
+	return 0
+}
+
+type ListRegionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Regions       []*Region              `protobuf:"bytes,1,rep,name=regions,proto3" json:"regions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRegionsResponse) Reset() {
+	*x = ListRegionsResponse{}
+	mi := &file_machine_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRegionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRegionsResponse) ProtoMessage() {}
+
+func (x *ListRegionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRegionsResponse.ProtoReflect.Descriptor instead.
+func (*ListRegionsResponse) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ListRegionsResponse) GetRegions() []*Region {
+	if x != nil {
+		return x.Regions
+	}
+	return nil
+}
+
+type HostRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	HostId        string                 `protobuf:"bytes,1,opt,name=host_id,json=hostId,proto3" json:"host_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HostRequest) Reset() {
+	*x = HostRequest{}
+	mi := &file_machine_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HostRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HostRequest) ProtoMessage() {}
+
+func (x *HostRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HostRequest.ProtoReflect.Descriptor instead.
+func (*HostRequest) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *HostRequest) GetHostId() string {
+	if x != nil {
+		return x.HostId
+	}
+	return ""
+}
+
+type HostResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	HostId string                 `protobuf:"bytes,1,opt,name=host_id,json=hostId,proto3" json:"host_id,omitempty"`
+	State  string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	// moved lists the machines migrated off the host by a drain or
+	// rescheduled elsewhere after a failure.
+	Moved []string `protobuf:"bytes,3,rep,name=moved,proto3" json:"moved,omitempty"`
+	// stranded lists the machines a drain could not place elsewhere or a
+	// failure left lost.
+	Stranded      []string `protobuf:"bytes,4,rep,name=stranded,proto3" json:"stranded,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HostResponse) Reset() {
+	*x = HostResponse{}
+	mi := &file_machine_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HostResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HostResponse) ProtoMessage() {}
+
+func (x *HostResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HostResponse.ProtoReflect.Descriptor instead.
+func (*HostResponse) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *HostResponse) GetHostId() string {
+	if x != nil {
+		return x.HostId
+	}
+	return ""
+}
+
+func (x *HostResponse) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *HostResponse) GetMoved() []string {
+	if x != nil {
+		return x.Moved
+	}
+	return nil
+}
+
+func (x *HostResponse) GetStranded() []string {
+	if x != nil {
+		return x.Stranded
+	}
+	return nil
+}
+
+type AcquireLeaseRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// ttl_seconds defaults to 30.
+	TtlSeconds    int32  `protobuf:"varint,2,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"`
+	Owner         string `protobuf:"bytes,3,opt,name=owner,proto3" json:"owner,omitempty"`
+	Description   string `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AcquireLeaseRequest) Reset() {
+	*x = AcquireLeaseRequest{}
+	mi := &file_machine_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AcquireLeaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AcquireLeaseRequest) ProtoMessage() {}
+
+func (x *AcquireLeaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AcquireLeaseRequest.ProtoReflect.Descriptor instead.
+func (*AcquireLeaseRequest) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *AcquireLeaseRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *AcquireLeaseRequest) GetTtlSeconds() int32 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+func (x *AcquireLeaseRequest) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *AcquireLeaseRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+type RenewLeaseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Nonce         string                 `protobuf:"bytes,2,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	TtlSeconds    int32                  `protobuf:"varint,3,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenewLeaseRequest) Reset() {
+	*x = RenewLeaseRequest{}
+	mi := &file_machine_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenewLeaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenewLeaseRequest) ProtoMessage() {}
+
+func (x *RenewLeaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenewLeaseRequest.ProtoReflect.Descriptor instead.
+func (*RenewLeaseRequest) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *RenewLeaseRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *RenewLeaseRequest) GetNonce() string {
+	if x != nil {
+		return x.Nonce
+	}
+	return ""
+}
+
+func (x *RenewLeaseRequest) GetTtlSeconds() int32 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+type ReleaseLeaseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Nonce         string                 `protobuf:"bytes,2,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReleaseLeaseRequest) Reset() {
+	*x = ReleaseLeaseRequest{}
+	mi := &file_machine_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReleaseLeaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReleaseLeaseRequest) ProtoMessage() {}
+
+func (x *ReleaseLeaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReleaseLeaseRequest.ProtoReflect.Descriptor instead.
+func (*ReleaseLeaseRequest) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ReleaseLeaseRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ReleaseLeaseRequest) GetNonce() string {
+	if x != nil {
+		return x.Nonce
+	}
+	return ""
+}
+
+type Lease struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	MachineId   string                 `protobuf:"bytes,1,opt,name=machine_id,json=machineId,proto3" json:"machine_id,omitempty"`
+	Nonce       string                 `protobuf:"bytes,2,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Owner       string                 `protobuf:"bytes,3,opt,name=owner,proto3" json:"owner,omitempty"`
+	Description string                 `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	// expires_at is a Unix timestamp in seconds.
+	ExpiresAt     int64 `protobuf:"varint,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Lease) Reset() {
+	*x = Lease{}
+	mi := &file_machine_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Lease) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Lease) ProtoMessage() {}
+
+func (x *Lease) ProtoReflect() protoreflect.Message {
+	mi := &file_machine_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Lease.ProtoReflect.Descriptor instead.
+func (*Lease) Descriptor() ([]byte, []int) {
+	return file_machine_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *Lease) GetMachineId() string {
+	if x != nil {
+		return x.MachineId
+	}
+	return ""
+}
+
+func (x *Lease) GetNonce() string {
+	if x != nil {
+		return x.Nonce
+	}
+	return ""
+}
+
+func (x *Lease) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *Lease) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Lease) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+var File_machine_proto protoreflect.FileDescriptor
+
+const file_machine_proto_rawDesc = "" +
 	"\n" +
 	"\rmachine.proto\x12\x13aerophoenix.machine\"\r\n" +
 	"\vPingRequest\" \n" +
 	"\fPingResponse\x12\x10\n" +
-	"\x03msg\x18\x01 \x01(\tR\x03msg\";\n" +
+	"\x03msg\x18\x01 \x01(\tR\x03msg\"H\n" +
+	"\rRestartPolicy\x12\x16\n" +
+	"\x06policy\x18\x01 \x01(\tR\x06policy\x12\x1f\n" +
+	"\vmax_retries\x18\x02 \x01(\x05R\n" +
+	"maxRetries\"8\n" +
+	"\x05Guest\x12\x12\n" +
+	"\x04cpus\x18\x01 \x01(\x05R\x04cpus\x12\x1b\n" +
+	"\tmemory_mb\x18\x02 \x01(\x05R\bmemoryMb\"\xd8\x01\n" +
+	"\vConstraints\x12\x12\n" +
+	"\x04host\x18\x01 \x01(\tR\x04host\x12W\n" +
+	"\ranti_affinity\x18\x02 \x03(\v22.aerophoenix.machine.Constraints.AntiAffinityEntryR\fantiAffinity\x12\x1b\n" +
+	"\tspread_by\x18\x03 \x01(\tR\bspreadBy\x1a?\n" +
+	"\x11AntiAffinityEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xa6\x03\n" +
 	"\rCreateRequest\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
-	"\x06region\x18\x02 \x01(\tR\x06region\"8\n" +
+	"\x06region\x18\x02 \x01(\tR\x06region\x12I\n" +
+	"\x0erestart_policy\x18\x03 \x01(\v2\".aerophoenix.machine.RestartPolicyR\rrestartPolicy\x120\n" +
+	"\x05guest\x18\x04 \x01(\v2\x1a.aerophoenix.machine.GuestR\x05guest\x12F\n" +
+	"\x06labels\x18\x05 \x03(\v2..aerophoenix.machine.CreateRequest.LabelsEntryR\x06labels\x12B\n" +
+	"\vconstraints\x18\x06 \x01(\v2 .aerophoenix.machine.ConstraintsR\vconstraints\x12%\n" +
+	"\x0epriority_class\x18\a \x01(\tR\rpriorityClass\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"]\n" +
 	"\x0eCreateResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
-	"\x06status\x18\x02 \x01(\tR\x06status\"\x1c\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12#\n" +
+	"\rstatus_reason\x18\x03 \x01(\tR\fstatusReason\"\x1c\n" +
 	"\n" +
 	"GetRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\"M\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x85\x05\n" +
 	"\vGetResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
 	"\x06status\x18\x02 \x01(\tR\x06status\x12\x16\n" +
-	"\x06region\x18\x03 \x01(\tR\x06region\"\x1f\n" +
+	"\x06region\x18\x03 \x01(\tR\x06region\x12\x1b\n" +
+	"\texit_code\x18\x04 \x01(\x05R\bexitCode\x12#\n" +
+	"\rrestart_count\x18\x05 \x01(\x05R\frestartCount\x12I\n" +
+	"\x0erestart_policy\x18\x06 \x01(\v2\".aerophoenix.machine.RestartPolicyR\rrestartPolicy\x12\x17\n" +
+	"\ahost_id\x18\a \x01(\tR\x06hostId\x120\n" +
+	"\x05guest\x18\b \x01(\v2\x1a.aerophoenix.machine.GuestR\x05guest\x12D\n" +
+	"\x06labels\x18\t \x03(\v2,.aerophoenix.machine.GetResponse.LabelsEntryR\x06labels\x12B\n" +
+	"\vconstraints\x18\n" +
+	" \x01(\v2 .aerophoenix.machine.ConstraintsR\vconstraints\x12\x12\n" +
+	"\x04zone\x18\v \x01(\tR\x04zone\x12#\n" +
+	"\rstatus_reason\x18\f \x01(\tR\fstatusReason\x12%\n" +
+	"\x0epriority_class\x18\r \x01(\tR\rpriorityClass\x129\n" +
+	"\bsnapshot\x18\x0e \x01(\v2\x1d.aerophoenix.machine.SnapshotR\bsnapshot\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"B\n" +
+	"\bSnapshot\x12\x1b\n" +
+	"\tmemory_mb\x18\x01 \x01(\x05R\bmemoryMb\x12\x19\n" +
+	"\btaken_at\x18\x02 \x01(\x03R\atakenAt\"T\n" +
+	"\vWaitRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x03 \x01(\x03R\ttimeoutMs\"d\n" +
+	"\fWaitResponse\x12:\n" +
+	"\amachine\x18\x01 \x01(\v2 .aerophoenix.machine.GetResponseR\amachine\x12\x18\n" +
+	"\aoutcome\x18\x02 \x01(\tR\aoutcome\"@\n" +
 	"\rActionRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\"(\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1f\n" +
+	"\vlease_nonce\x18\x02 \x01(\tR\n" +
+	"leaseNonce\"(\n" +
 	"\x0eActionResponse\x12\x16\n" +
-	"\x06result\x18\x01 \x01(\tR\x06result2\xb9\x03\n" +
+	"\x06result\x18\x01 \x01(\tR\x06result\"f\n" +
+	"\x0eMigrateRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12#\n" +
+	"\rtarget_region\x18\x02 \x01(\tR\ftargetRegion\x12\x1f\n" +
+	"\vlease_nonce\x18\x03 \x01(\tR\n" +
+	"leaseNonce\"\x14\n" +
+	"\x12ListRegionsRequest\";\n" +
+	"\bCapacity\x12\x12\n" +
+	"\x04cpus\x18\x01 \x01(\x05R\x04cpus\x12\x1b\n" +
+	"\tmemory_mb\x18\x02 \x01(\x05R\bmemoryMb\"\xe3\x02\n" +
+	"\x06Region\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1a\n" +
+	"\blatitude\x18\x03 \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\x04 \x01(\x01R\tlongitude\x12\x14\n" +
+	"\x05hosts\x18\x05 \x01(\x05R\x05hosts\x12\x1a\n" +
+	"\bmachines\x18\x06 \x01(\x05R\bmachines\x129\n" +
+	"\bcapacity\x18\a \x01(\v2\x1d.aerophoenix.machine.CapacityR\bcapacity\x121\n" +
+	"\x04used\x18\b \x01(\v2\x1d.aerophoenix.machine.CapacityR\x04used\x12 \n" +
+	"\vpartitioned\x18\t \x01(\bR\vpartitioned\x12\x1d\n" +
+	"\n" +
+	"latency_ms\x18\n" +
+	" \x01(\x05R\tlatencyMs\x12\x16\n" +
+	"\x06queued\x18\v \x01(\x05R\x06queued\"L\n" +
+	"\x13ListRegionsResponse\x125\n" +
+	"\aregions\x18\x01 \x03(\v2\x1b.aerophoenix.machine.RegionR\aregions\"&\n" +
+	"\vHostRequest\x12\x17\n" +
+	"\ahost_id\x18\x01 \x01(\tR\x06hostId\"o\n" +
+	"\fHostResponse\x12\x17\n" +
+	"\ahost_id\x18\x01 \x01(\tR\x06hostId\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12\x14\n" +
+	"\x05moved\x18\x03 \x03(\tR\x05moved\x12\x1a\n" +
+	"\bstranded\x18\x04 \x03(\tR\bstranded\"~\n" +
+	"\x13AcquireLeaseRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1f\n" +
+	"\vttl_seconds\x18\x02 \x01(\x05R\n" +
+	"ttlSeconds\x12\x14\n" +
+	"\x05owner\x18\x03 \x01(\tR\x05owner\x12 \n" +
+	"\vdescription\x18\x04 \x01(\tR\vdescription\"Z\n" +
+	"\x11RenewLeaseRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05nonce\x18\x02 \x01(\tR\x05nonce\x12\x1f\n" +
+	"\vttl_seconds\x18\x03 \x01(\x05R\n" +
+	"ttlSeconds\";\n" +
+	"\x13ReleaseLeaseRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05nonce\x18\x02 \x01(\tR\x05nonce\"\x93\x01\n" +
+	"\x05Lease\x12\x1d\n" +
+	"\n" +
+	"machine_id\x18\x01 \x01(\tR\tmachineId\x12\x14\n" +
+	"\x05nonce\x18\x02 \x01(\tR\x05nonce\x12\x14\n" +
+	"\x05owner\x18\x03 \x01(\tR\x05owner\x12 \n" +
+	"\vdescription\x18\x04 \x01(\tR\vdescription\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x05 \x01(\x03R\texpiresAt2\xd1\r\n" +
 	"\x0eMachineService\x12K\n" +
 	"\x04Ping\x12 .aerophoenix.machine.PingRequest\x1a!.aerophoenix.machine.PingResponse\x12X\n" +
 	"\rCreateMachine\x12\".aerophoenix.machine.CreateRequest\x1a#.aerophoenix.machine.CreateResponse\x12O\n" +
 	"\n" +
-	"GetMachine\x12\x1f.aerophoenix.machine.GetRequest\x1a .aerophoenix.machine.GetResponse\x12W\n" +
+	"GetMachine\x12\x1f.aerophoenix.machine.GetRequest\x1a .aerophoenix.machine.GetResponse\x12R\n" +
+	"\vWaitMachine\x12 .aerophoenix.machine.WaitRequest\x1a!.aerophoenix.machine.WaitResponse\x12W\n" +
 	"\fStartMachine\x12\".aerophoenix.machine.ActionRequest\x1a#.aerophoenix.machine.ActionResponse\x12V\n" +
-	"\vStopMachine\x12\".aerophoenix.machine.ActionRequest\x1a#.aerophoenix.machine.ActionResponseBAZ?github.com/devghori1264/aerophoenix/apps/flyd-sim/proto;machineb\x06proto3"
+	"\vStopMachine\x12\".aerophoenix.machine.ActionRequest\x1a#.aerophoenix.machine.ActionResponse\x12Y\n" +
+	"\x0eDestroyMachine\x12\".aerophoenix.machine.ActionRequest\x1a#.aerophoenix.machine.ActionResponse\x12Y\n" +
+	"\x0eRestartMachine\x12\".aerophoenix.machine.ActionRequest\x1a#.aerophoenix.machine.ActionResponse\x12Y\n" +
+	"\x0eSuspendMachine\x12\".aerophoenix.machine.ActionRequest\x1a#.aerophoenix.machine.ActionResponse\x12X\n" +
+	"\rResumeMachine\x12\".aerophoenix.machine.ActionRequest\x1a#.aerophoenix.machine.ActionResponse\x12T\n" +
+	"\fAcquireLease\x12(.aerophoenix.machine.AcquireLeaseRequest\x1a\x1a.aerophoenix.machine.Lease\x12P\n" +
+	"\n" +
+	"RenewLease\x12&.aerophoenix.machine.RenewLeaseRequest\x1a\x1a.aerophoenix.machine.Lease\x12]\n" +
+	"\fReleaseLease\x12(.aerophoenix.machine.ReleaseLeaseRequest\x1a#.aerophoenix.machine.ActionResponse\x12G\n" +
+	"\bGetLease\x12\x1f.aerophoenix.machine.GetRequest\x1a\x1a.aerophoenix.machine.Lease\x12Z\n" +
+	"\x0eMigrateMachine\x12#.aerophoenix.machine.MigrateRequest\x1a#.aerophoenix.machine.ActionResponse\x12`\n" +
+	"\vListRegions\x12'.aerophoenix.machine.ListRegionsRequest\x1a(.aerophoenix.machine.ListRegionsResponse\x12Q\n" +
+	"\n" +
+	"CordonHost\x12 .aerophoenix.machine.HostRequest\x1a!.aerophoenix.machine.HostResponse\x12S\n" +
+	"\fUncordonHost\x12 .aerophoenix.machine.HostRequest\x1a!.aerophoenix.machine.HostResponse\x12P\n" +
+	"\tDrainHost\x12 .aerophoenix.machine.HostRequest\x1a!.aerophoenix.machine.HostResponse\x12O\n" +
+	"\bFailHost\x12 .aerophoenix.machine.HostRequest\x1a!.aerophoenix.machine.HostResponseBAZ?github.com/devghori1264/aerophoenix/apps/flyd-sim/proto;machineb\x06proto3"
 
 var (
 	file_machine_proto_rawDescOnce sync.Once
@@ -442,33 +1755,97 @@ func file_machine_proto_rawDescGZIP() []byte {
 	return file_machine_proto_rawDescData
 }
 
-var file_machine_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_machine_proto_msgTypes = make([]protoimpl.MessageInfo, 28)
 var file_machine_proto_goTypes = []any{
-	(*PingRequest)(nil),    // 0: aerophoenix.machine.PingRequest
-	(*PingResponse)(nil),   // 1: aerophoenix.machine.PingResponse
-	(*CreateRequest)(nil),  // 2: aerophoenix.machine.CreateRequest
-	(*CreateResponse)(nil), // 3: aerophoenix.machine.CreateResponse
-	(*GetRequest)(nil),     // 4: aerophoenix.machine.GetRequest
-	(*GetResponse)(nil),    // 5: aerophoenix.machine.GetResponse
-	(*ActionRequest)(nil),  // 6: aerophoenix.machine.ActionRequest
-	(*ActionResponse)(nil), // 7: aerophoenix.machine.ActionResponse
+	(*PingRequest)(nil),         // 0: aerophoenix.machine.PingRequest
+	(*PingResponse)(nil),        // 1: aerophoenix.machine.PingResponse
+	(*RestartPolicy)(nil),       // 2: aerophoenix.machine.RestartPolicy
+	(*Guest)(nil),               // 3: aerophoenix.machine.Guest
+	(*Constraints)(nil),         // 4: aerophoenix.machine.Constraints
+	(*CreateRequest)(nil),       // 5: aerophoenix.machine.CreateRequest
+	(*CreateResponse)(nil),      // 6: aerophoenix.machine.CreateResponse
+	(*GetRequest)(nil),          // 7: aerophoenix.machine.GetRequest
+	(*GetResponse)(nil),         // 8: aerophoenix.machine.GetResponse
+	(*Snapshot)(nil),            // 9: aerophoenix.machine.Snapshot
+	(*WaitRequest)(nil),         // 10: aerophoenix.machine.WaitRequest
+	(*WaitResponse)(nil),        // 11: aerophoenix.machine.WaitResponse
+	(*ActionRequest)(nil),       // 12: aerophoenix.machine.ActionRequest
+	(*ActionResponse)(nil),      // 13: aerophoenix.machine.ActionResponse
+	(*MigrateRequest)(nil),      // 14: aerophoenix.machine.MigrateRequest
+	(*ListRegionsRequest)(nil),  // 15: aerophoenix.machine.ListRegionsRequest
+	(*Capacity)(nil),            // 16: aerophoenix.machine.Capacity
+	(*Region)(nil),              // 17: aerophoenix.machine.Region
+	(*ListRegionsResponse)(nil), // 18: aerophoenix.machine.ListRegionsResponse
+	(*HostRequest)(nil),         // 19: aerophoenix.machine.HostRequest
+	(*HostResponse)(nil),        // 20: aerophoenix.machine.HostResponse
+	(*AcquireLeaseRequest)(nil), // 21: aerophoenix.machine.AcquireLeaseRequest
+	(*RenewLeaseRequest)(nil),   // 22: aerophoenix.machine.RenewLeaseRequest
+	(*ReleaseLeaseRequest)(nil), // 23: aerophoenix.machine.ReleaseLeaseRequest
+	(*Lease)(nil),               // 24: aerophoenix.machine.Lease
+	nil,                         // 25: aerophoenix.machine.Constraints.AntiAffinityEntry
+	nil,                         // 26: aerophoenix.machine.CreateRequest.LabelsEntry
+	nil,                         // 27: aerophoenix.machine.GetResponse.LabelsEntry
 }
 var file_machine_proto_depIdxs = []int32{
-	0, // 0: aerophoenix.machine.MachineService.Ping:input_type -> aerophoenix.machine.PingRequest
-	2, // 1: aerophoenix.machine.MachineService.CreateMachine:input_type -> aerophoenix.machine.CreateRequest
-	4, // 2: aerophoenix.machine.MachineService.GetMachine:input_type -> aerophoenix.machine.GetRequest
-	6, // 3: aerophoenix.machine.MachineService.StartMachine:input_type -> aerophoenix.machine.ActionRequest
-	6, // 4: aerophoenix.machine.MachineService.StopMachine:input_type -> aerophoenix.machine.ActionRequest
-	1, // 5: aerophoenix.machine.MachineService.Ping:output_type -> aerophoenix.machine.PingResponse
-	3, // 6: aerophoenix.machine.MachineService.CreateMachine:output_type -> aerophoenix.machine.CreateResponse
-	5, // 7: aerophoenix.machine.MachineService.GetMachine:output_type -> aerophoenix.machine.GetResponse
-	7, // 8: aerophoenix.machine.MachineService.StartMachine:output_type -> aerophoenix.machine.ActionResponse
-	7, // 9: aerophoenix.machine.MachineService.StopMachine:output_type -> aerophoenix.machine.ActionResponse
-	5, // [5:10] is the sub-list for method output_type
-	0, // [0:5] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	25, // 0: aerophoenix.machine.Constraints.anti_affinity:type_name -> aerophoenix.machine.Constraints.AntiAffinityEntry
+	2,  // 1: aerophoenix.machine.CreateRequest.restart_policy:type_name -> aerophoenix.machine.RestartPolicy
+	3,  // 2: aerophoenix.machine.CreateRequest.guest:type_name -> aerophoenix.machine.Guest
+	26, // 3: aerophoenix.machine.CreateRequest.labels:type_name -> aerophoenix.machine.CreateRequest.LabelsEntry
+	4,  // 4: aerophoenix.machine.CreateRequest.constraints:type_name -> aerophoenix.machine.Constraints
+	2,  // 5: aerophoenix.machine.GetResponse.restart_policy:type_name -> aerophoenix.machine.RestartPolicy
+	3,  // 6: aerophoenix.machine.GetResponse.guest:type_name -> aerophoenix.machine.Guest
+	27, // 7: aerophoenix.machine.GetResponse.labels:type_name -> aerophoenix.machine.GetResponse.LabelsEntry
+	4,  // 8: aerophoenix.machine.GetResponse.constraints:type_name -> aerophoenix.machine.Constraints
+	9,  // 9: aerophoenix.machine.GetResponse.snapshot:type_name -> aerophoenix.machine.Snapshot
+	8,  // 10: aerophoenix.machine.WaitResponse.machine:type_name -> aerophoenix.machine.GetResponse
+	16, // 11: aerophoenix.machine.Region.capacity:type_name -> aerophoenix.machine.Capacity
+	16, // 12: aerophoenix.machine.Region.used:type_name -> aerophoenix.machine.Capacity
+	17, // 13: aerophoenix.machine.ListRegionsResponse.regions:type_name -> aerophoenix.machine.Region
+	0,  // 14: aerophoenix.machine.MachineService.Ping:input_type -> aerophoenix.machine.PingRequest
+	5,  // 15: aerophoenix.machine.MachineService.CreateMachine:input_type -> aerophoenix.machine.CreateRequest
+	7,  // 16: aerophoenix.machine.MachineService.GetMachine:input_type -> aerophoenix.machine.GetRequest
+	10, // 17: aerophoenix.machine.MachineService.WaitMachine:input_type -> aerophoenix.machine.WaitRequest
+	12, // 18: aerophoenix.machine.MachineService.StartMachine:input_type -> aerophoenix.machine.ActionRequest
+	12, // 19: aerophoenix.machine.MachineService.StopMachine:input_type -> aerophoenix.machine.ActionRequest
+	12, // 20: aerophoenix.machine.MachineService.DestroyMachine:input_type -> aerophoenix.machine.ActionRequest
+	12, // 21: aerophoenix.machine.MachineService.RestartMachine:input_type -> aerophoenix.machine.ActionRequest
+	12, // 22: aerophoenix.machine.MachineService.SuspendMachine:input_type -> aerophoenix.machine.ActionRequest
+	12, // 23: aerophoenix.machine.MachineService.ResumeMachine:input_type -> aerophoenix.machine.ActionRequest
+	21, // 24: aerophoenix.machine.MachineService.AcquireLease:input_type -> aerophoenix.machine.AcquireLeaseRequest
+	22, // 25: aerophoenix.machine.MachineService.RenewLease:input_type -> aerophoenix.machine.RenewLeaseRequest
+	23, // 26: aerophoenix.machine.MachineService.ReleaseLease:input_type -> aerophoenix.machine.ReleaseLeaseRequest
+	7,  // 27: aerophoenix.machine.MachineService.GetLease:input_type -> aerophoenix.machine.GetRequest
+	14, // 28: aerophoenix.machine.MachineService.MigrateMachine:input_type -> aerophoenix.machine.MigrateRequest
+	15, // 29: aerophoenix.machine.MachineService.ListRegions:input_type -> aerophoenix.machine.ListRegionsRequest
+	19, // 30: aerophoenix.machine.MachineService.CordonHost:input_type -> aerophoenix.machine.HostRequest
+	19, // 31: aerophoenix.machine.MachineService.UncordonHost:input_type -> aerophoenix.machine.HostRequest
+	19, // 32: aerophoenix.machine.MachineService.DrainHost:input_type -> aerophoenix.machine.HostRequest
+	19, // 33: aerophoenix.machine.MachineService.FailHost:input_type -> aerophoenix.machine.HostRequest
+	1,  // 34: aerophoenix.machine.MachineService.Ping:output_type -> aerophoenix.machine.PingResponse
+	6,  // 35: aerophoenix.machine.MachineService.CreateMachine:output_type -> aerophoenix.machine.CreateResponse
+	8,  // 36: aerophoenix.machine.MachineService.GetMachine:output_type -> aerophoenix.machine.GetResponse
+	11, // 37: aerophoenix.machine.MachineService.WaitMachine:output_type -> aerophoenix.machine.WaitResponse
+	13, // 38: aerophoenix.machine.MachineService.StartMachine:output_type -> aerophoenix.machine.ActionResponse
+	13, // 39: aerophoenix.machine.MachineService.StopMachine:output_type -> aerophoenix.machine.ActionResponse
+	13, // 40: aerophoenix.machine.MachineService.DestroyMachine:output_type -> aerophoenix.machine.ActionResponse
+	13, // 41: aerophoenix.machine.MachineService.RestartMachine:output_type -> aerophoenix.machine.ActionResponse
+	13, // 42: aerophoenix.machine.MachineService.SuspendMachine:output_type -> aerophoenix.machine.ActionResponse
+	13, // 43: aerophoenix.machine.MachineService.ResumeMachine:output_type -> aerophoenix.machine.ActionResponse
+	24, // 44: aerophoenix.machine.MachineService.AcquireLease:output_type -> aerophoenix.machine.Lease
+	24, // 45: aerophoenix.machine.MachineService.RenewLease:output_type -> aerophoenix.machine.Lease
+	13, // 46: aerophoenix.machine.MachineService.ReleaseLease:output_type -> aerophoenix.machine.ActionResponse
+	24, // 47: aerophoenix.machine.MachineService.GetLease:output_type -> aerophoenix.machine.Lease
+	13, // 48: aerophoenix.machine.MachineService.MigrateMachine:output_type -> aerophoenix.machine.ActionResponse
+	18, // 49: aerophoenix.machine.MachineService.ListRegions:output_type -> aerophoenix.machine.ListRegionsResponse
+	20, // 50: aerophoenix.machine.MachineService.CordonHost:output_type -> aerophoenix.machine.HostResponse
+	20, // 51: aerophoenix.machine.MachineService.UncordonHost:output_type -> aerophoenix.machine.HostResponse
+	20, // 52: aerophoenix.machine.MachineService.DrainHost:output_type -> aerophoenix.machine.HostResponse
+	20, // 53: aerophoenix.machine.MachineService.FailHost:output_type -> aerophoenix.machine.HostResponse
+	34, // [34:54] is the sub-list for method output_type
+	14, // [14:34] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
 }
 
 func init() { file_machine_proto_init() }
@@ -482,7 +1859,7 @@ func file_machine_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_machine_proto_rawDesc), len(file_machine_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   8,
+			NumMessages:   28,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,11 +19,26 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	MachineService_Ping_FullMethodName          = "/aerophoenix.machine.MachineService/Ping"
-	MachineService_CreateMachine_FullMethodName = "/aerophoenix.machine.MachineService/CreateMachine"
-	MachineService_GetMachine_FullMethodName    = "/aerophoenix.machine.MachineService/GetMachine"
-	MachineService_StartMachine_FullMethodName  = "/aerophoenix.machine.MachineService/StartMachine"
-	MachineService_StopMachine_FullMethodName   = "/aerophoenix.machine.MachineService/StopMachine"
+	MachineService_Ping_FullMethodName           = "/aerophoenix.machine.MachineService/Ping"
+	MachineService_CreateMachine_FullMethodName  = "/aerophoenix.machine.MachineService/CreateMachine"
+	MachineService_GetMachine_FullMethodName     = "/aerophoenix.machine.MachineService/GetMachine"
+	MachineService_WaitMachine_FullMethodName    = "/aerophoenix.machine.MachineService/WaitMachine"
+	MachineService_StartMachine_FullMethodName   = "/aerophoenix.machine.MachineService/StartMachine"
+	MachineService_StopMachine_FullMethodName    = "/aerophoenix.machine.MachineService/StopMachine"
+	MachineService_DestroyMachine_FullMethodName = "/aerophoenix.machine.MachineService/DestroyMachine"
+	MachineService_RestartMachine_FullMethodName = "/aerophoenix.machine.MachineService/RestartMachine"
+	MachineService_SuspendMachine_FullMethodName = "/aerophoenix.machine.MachineService/SuspendMachine"
+	MachineService_ResumeMachine_FullMethodName  = "/aerophoenix.machine.MachineService/ResumeMachine"
+	MachineService_AcquireLease_FullMethodName   = "/aerophoenix.machine.MachineService/AcquireLease"
+	MachineService_RenewLease_FullMethodName     = "/aerophoenix.machine.MachineService/RenewLease"
+	MachineService_ReleaseLease_FullMethodName   = "/aerophoenix.machine.MachineService/ReleaseLease"
+	MachineService_GetLease_FullMethodName       = "/aerophoenix.machine.MachineService/GetLease"
+	MachineService_MigrateMachine_FullMethodName = "/aerophoenix.machine.MachineService/MigrateMachine"
+	MachineService_ListRegions_FullMethodName    = "/aerophoenix.machine.MachineService/ListRegions"
+	MachineService_CordonHost_FullMethodName     = "/aerophoenix.machine.MachineService/CordonHost"
+	MachineService_UncordonHost_FullMethodName   = "/aerophoenix.machine.MachineService/UncordonHost"
+	MachineService_DrainHost_FullMethodName      = "/aerophoenix.machine.MachineService/DrainHost"
+	MachineService_FailHost_FullMethodName       = "/aerophoenix.machine.MachineService/FailHost"
 )
 
 // MachineServiceClient is the client API for MachineService service.
@@ -33,8 +48,36 @@ type MachineServiceClient interface {
 	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
 	CreateMachine(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error)
 	GetMachine(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	// WaitMachine blocks until a machine reaches a status, fails for good or
+	// the timeout passes, and returns the machine as it then stands.
+	WaitMachine(ctx context.Context, in *WaitRequest, opts ...grpc.CallOption) (*WaitResponse, error)
 	StartMachine(ctx context.Context, in *ActionRequest, opts ...grpc.CallOption) (*ActionResponse, error)
 	StopMachine(ctx context.Context, in *ActionRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	// DestroyMachine terminates a machine and frees its host capacity.
+	DestroyMachine(ctx context.Context, in *ActionRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	// RestartMachine cold boots a machine again on its host.
+	RestartMachine(ctx context.Context, in *ActionRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	// SuspendMachine snapshots a running machine's memory and pauses it;
+	// ResumeMachine boots it from the snapshot, faster than a cold start.
+	SuspendMachine(ctx context.Context, in *ActionRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	ResumeMachine(ctx context.Context, in *ActionRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	// Leases give one client exclusive control of a machine: while a lease is
+	// held, mutating calls must carry its nonce. Leases expire after their TTL
+	// unless renewed.
+	AcquireLease(ctx context.Context, in *AcquireLeaseRequest, opts ...grpc.CallOption) (*Lease, error)
+	RenewLease(ctx context.Context, in *RenewLeaseRequest, opts ...grpc.CallOption) (*Lease, error)
+	ReleaseLease(ctx context.Context, in *ReleaseLeaseRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	GetLease(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Lease, error)
+	MigrateMachine(ctx context.Context, in *MigrateRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	ListRegions(ctx context.Context, in *ListRegionsRequest, opts ...grpc.CallOption) (*ListRegionsResponse, error)
+	// Host administration. Cordoning stops new placements on a host, draining
+	// also migrates its machines to other hosts in the region, and failing it
+	// loses its machines and reschedules them per their restart policy.
+	// Uncordoning returns a cordoned or failed host to service.
+	CordonHost(ctx context.Context, in *HostRequest, opts ...grpc.CallOption) (*HostResponse, error)
+	UncordonHost(ctx context.Context, in *HostRequest, opts ...grpc.CallOption) (*HostResponse, error)
+	DrainHost(ctx context.Context, in *HostRequest, opts ...grpc.CallOption) (*HostResponse, error)
+	FailHost(ctx context.Context, in *HostRequest, opts ...grpc.CallOption) (*HostResponse, error)
 }
 
 type machineServiceClient struct {
@@ -75,6 +118,16 @@ func (c *machineServiceClient) GetMachine(ctx context.Context, in *GetRequest, o
 	return out, nil
 }
 
+func (c *machineServiceClient) WaitMachine(ctx context.Context, in *WaitRequest, opts ...grpc.CallOption) (*WaitResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WaitResponse)
+	err := c.cc.Invoke(ctx, MachineService_WaitMachine_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *machineServiceClient) StartMachine(ctx context.Context, in *ActionRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(ActionResponse)
@@ -95,6 +148,146 @@ func (c *machineServiceClient) StopMachine(ctx context.Context, in *ActionReques
 	return out, nil
 }
 
+func (c *machineServiceClient) DestroyMachine(ctx context.Context, in *ActionRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, MachineService_DestroyMachine_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machineServiceClient) RestartMachine(ctx context.Context, in *ActionRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, MachineService_RestartMachine_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machineServiceClient) SuspendMachine(ctx context.Context, in *ActionRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, MachineService_SuspendMachine_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machineServiceClient) ResumeMachine(ctx context.Context, in *ActionRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, MachineService_ResumeMachine_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machineServiceClient) AcquireLease(ctx context.Context, in *AcquireLeaseRequest, opts ...grpc.CallOption) (*Lease, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Lease)
+	err := c.cc.Invoke(ctx, MachineService_AcquireLease_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machineServiceClient) RenewLease(ctx context.Context, in *RenewLeaseRequest, opts ...grpc.CallOption) (*Lease, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Lease)
+	err := c.cc.Invoke(ctx, MachineService_RenewLease_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machineServiceClient) ReleaseLease(ctx context.Context, in *ReleaseLeaseRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, MachineService_ReleaseLease_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machineServiceClient) GetLease(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Lease, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Lease)
+	err := c.cc.Invoke(ctx, MachineService_GetLease_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machineServiceClient) MigrateMachine(ctx context.Context, in *MigrateRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, MachineService_MigrateMachine_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machineServiceClient) ListRegions(ctx context.Context, in *ListRegionsRequest, opts ...grpc.CallOption) (*ListRegionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRegionsResponse)
+	err := c.cc.Invoke(ctx, MachineService_ListRegions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machineServiceClient) CordonHost(ctx context.Context, in *HostRequest, opts ...grpc.CallOption) (*HostResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HostResponse)
+	err := c.cc.Invoke(ctx, MachineService_CordonHost_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machineServiceClient) UncordonHost(ctx context.Context, in *HostRequest, opts ...grpc.CallOption) (*HostResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HostResponse)
+	err := c.cc.Invoke(ctx, MachineService_UncordonHost_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machineServiceClient) DrainHost(ctx context.Context, in *HostRequest, opts ...grpc.CallOption) (*HostResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HostResponse)
+	err := c.cc.Invoke(ctx, MachineService_DrainHost_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *machineServiceClient) FailHost(ctx context.Context, in *HostRequest, opts ...grpc.CallOption) (*HostResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HostResponse)
+	err := c.cc.Invoke(ctx, MachineService_FailHost_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MachineServiceServer is the server API for MachineService service.
 // All implementations must embed UnimplementedMachineServiceServer
 // for forward compatibility.
@@ -102,8 +295,36 @@ type MachineServiceServer interface {
 	Ping(context.Context, *PingRequest) (*PingResponse, error)
 	CreateMachine(context.Context, *CreateRequest) (*CreateResponse, error)
 	GetMachine(context.Context, *GetRequest) (*GetResponse, error)
+	// WaitMachine blocks until a machine reaches a status, fails for good or
+	// the timeout passes, and returns the machine as it then stands.
+	WaitMachine(context.Context, *WaitRequest) (*WaitResponse, error)
 	StartMachine(context.Context, *ActionRequest) (*ActionResponse, error)
 	StopMachine(context.Context, *ActionRequest) (*ActionResponse, error)
+	// DestroyMachine terminates a machine and frees its host capacity.
+	DestroyMachine(context.Context, *ActionRequest) (*ActionResponse, error)
+	// RestartMachine cold boots a machine again on its host.
+	RestartMachine(context.Context, *ActionRequest) (*ActionResponse, error)
+	// SuspendMachine snapshots a running machine's memory and pauses it;
+	// ResumeMachine boots it from the snapshot, faster than a cold start.
+	SuspendMachine(context.Context, *ActionRequest) (*ActionResponse, error)
+	ResumeMachine(context.Context, *ActionRequest) (*ActionResponse, error)
+	// Leases give one client exclusive control of a machine: while a lease is
+	// held, mutating calls must carry its nonce. Leases expire after their TTL
+	// unless renewed.
+	AcquireLease(context.Context, *AcquireLeaseRequest) (*Lease, error)
+	RenewLease(context.Context, *RenewLeaseRequest) (*Lease, error)
+	ReleaseLease(context.Context, *ReleaseLeaseRequest) (*ActionResponse, error)
+	GetLease(context.Context, *GetRequest) (*Lease, error)
+	MigrateMachine(context.Context, *MigrateRequest) (*ActionResponse, error)
+	ListRegions(context.Context, *ListRegionsRequest) (*ListRegionsResponse, error)
+	// Host administration. Cordoning stops new placements on a host, draining
+	// also migrates its machines to other hosts in the region, and failing it
+	// loses its machines and reschedules them per their restart policy.
+	// Uncordoning returns a cordoned or failed host to service.
+	CordonHost(context.Context, *HostRequest) (*HostResponse, error)
+	UncordonHost(context.Context, *HostRequest) (*HostResponse, error)
+	DrainHost(context.Context, *HostRequest) (*HostResponse, error)
+	FailHost(context.Context, *HostRequest) (*HostResponse, error)
 	mustEmbedUnimplementedMachineServiceServer()
 }
 
@@ -123,12 +344,57 @@ func (UnimplementedMachineServiceServer) CreateMachine(context.Context, *CreateR
 func (UnimplementedMachineServiceServer) GetMachine(context.Context, *GetRequest) (*GetResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetMachine not implemented")
 }
+func (UnimplementedMachineServiceServer) WaitMachine(context.Context, *WaitRequest) (*WaitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WaitMachine not implemented")
+}
 func (UnimplementedMachineServiceServer) StartMachine(context.Context, *ActionRequest) (*ActionResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method StartMachine not implemented")
 }
 func (UnimplementedMachineServiceServer) StopMachine(context.Context, *ActionRequest) (*ActionResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method StopMachine not implemented")
 }
+func (UnimplementedMachineServiceServer) DestroyMachine(context.Context, *ActionRequest) (*ActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DestroyMachine not implemented")
+}
+func (UnimplementedMachineServiceServer) RestartMachine(context.Context, *ActionRequest) (*ActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestartMachine not implemented")
+}
+func (UnimplementedMachineServiceServer) SuspendMachine(context.Context, *ActionRequest) (*ActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SuspendMachine not implemented")
+}
+func (UnimplementedMachineServiceServer) ResumeMachine(context.Context, *ActionRequest) (*ActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResumeMachine not implemented")
+}
+func (UnimplementedMachineServiceServer) AcquireLease(context.Context, *AcquireLeaseRequest) (*Lease, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcquireLease not implemented")
+}
+func (UnimplementedMachineServiceServer) RenewLease(context.Context, *RenewLeaseRequest) (*Lease, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenewLease not implemented")
+}
+func (UnimplementedMachineServiceServer) ReleaseLease(context.Context, *ReleaseLeaseRequest) (*ActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleaseLease not implemented")
+}
+func (UnimplementedMachineServiceServer) GetLease(context.Context, *GetRequest) (*Lease, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLease not implemented")
+}
+func (UnimplementedMachineServiceServer) MigrateMachine(context.Context, *MigrateRequest) (*ActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MigrateMachine not implemented")
+}
+func (UnimplementedMachineServiceServer) ListRegions(context.Context, *ListRegionsRequest) (*ListRegionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRegions not implemented")
+}
+func (UnimplementedMachineServiceServer) CordonHost(context.Context, *HostRequest) (*HostResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CordonHost not implemented")
+}
+func (UnimplementedMachineServiceServer) UncordonHost(context.Context, *HostRequest) (*HostResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UncordonHost not implemented")
+}
+func (UnimplementedMachineServiceServer) DrainHost(context.Context, *HostRequest) (*HostResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DrainHost not implemented")
+}
+func (UnimplementedMachineServiceServer) FailHost(context.Context, *HostRequest) (*HostResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FailHost not implemented")
+}
 func (UnimplementedMachineServiceServer) mustEmbedUnimplementedMachineServiceServer() {}
 func (UnimplementedMachineServiceServer) testEmbeddedByValue()                        {}
 
@@ -204,6 +470,24 @@ func _MachineService_GetMachine_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MachineService_WaitMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WaitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).WaitMachine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_WaitMachine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).WaitMachine(ctx, req.(*WaitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _MachineService_StartMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ActionRequest)
 	if err := dec(in); err != nil {
@@ -240,6 +524,258 @@ func _MachineService_StopMachine_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MachineService_DestroyMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).DestroyMachine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_DestroyMachine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).DestroyMachine(ctx, req.(*ActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MachineService_RestartMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).RestartMachine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_RestartMachine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).RestartMachine(ctx, req.(*ActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MachineService_SuspendMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).SuspendMachine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_SuspendMachine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).SuspendMachine(ctx, req.(*ActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MachineService_ResumeMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).ResumeMachine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_ResumeMachine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).ResumeMachine(ctx, req.(*ActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MachineService_AcquireLease_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AcquireLeaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).AcquireLease(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_AcquireLease_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).AcquireLease(ctx, req.(*AcquireLeaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MachineService_RenewLease_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenewLeaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).RenewLease(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_RenewLease_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).RenewLease(ctx, req.(*RenewLeaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MachineService_ReleaseLease_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReleaseLeaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).ReleaseLease(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_ReleaseLease_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).ReleaseLease(ctx, req.(*ReleaseLeaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MachineService_GetLease_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).GetLease(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_GetLease_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).GetLease(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MachineService_MigrateMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MigrateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).MigrateMachine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_MigrateMachine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).MigrateMachine(ctx, req.(*MigrateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MachineService_ListRegions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRegionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).ListRegions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_ListRegions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).ListRegions(ctx, req.(*ListRegionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MachineService_CordonHost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HostRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).CordonHost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_CordonHost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).CordonHost(ctx, req.(*HostRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MachineService_UncordonHost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HostRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).UncordonHost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_UncordonHost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).UncordonHost(ctx, req.(*HostRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MachineService_DrainHost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HostRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).DrainHost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_DrainHost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).DrainHost(ctx, req.(*HostRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MachineService_FailHost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HostRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MachineServiceServer).FailHost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MachineService_FailHost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MachineServiceServer).FailHost(ctx, req.(*HostRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // MachineService_ServiceDesc is the grpc.ServiceDesc for MachineService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -259,6 +795,10 @@ var MachineService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetMachine",
 			Handler:    _MachineService_GetMachine_Handler,
 		},
+		{
+			MethodName: "WaitMachine",
+			Handler:    _MachineService_WaitMachine_Handler,
+		},
 		{
 			MethodName: "StartMachine",
 			Handler:    _MachineService_StartMachine_Handler,
@@ -267,6 +807,62 @@ var MachineService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "StopMachine",
 			Handler:    _MachineService_StopMachine_Handler,
 		},
+		{
+			MethodName: "DestroyMachine",
+			Handler:    _MachineService_DestroyMachine_Handler,
+		},
+		{
+			MethodName: "RestartMachine",
+			Handler:    _MachineService_RestartMachine_Handler,
+		},
+		{
+			MethodName: "SuspendMachine",
+			Handler:    _MachineService_SuspendMachine_Handler,
+		},
+		{
+			MethodName: "ResumeMachine",
+			Handler:    _MachineService_ResumeMachine_Handler,
+		},
+		{
+			MethodName: "AcquireLease",
+			Handler:    _MachineService_AcquireLease_Handler,
+		},
+		{
+			MethodName: "RenewLease",
+			Handler:    _MachineService_RenewLease_Handler,
+		},
+		{
+			MethodName: "ReleaseLease",
+			Handler:    _MachineService_ReleaseLease_Handler,
+		},
+		{
+			MethodName: "GetLease",
+			Handler:    _MachineService_GetLease_Handler,
+		},
+		{
+			MethodName: "MigrateMachine",
+			Handler:    _MachineService_MigrateMachine_Handler,
+		},
+		{
+			MethodName: "ListRegions",
+			Handler:    _MachineService_ListRegions_Handler,
+		},
+		{
+			MethodName: "CordonHost",
+			Handler:    _MachineService_CordonHost_Handler,
+		},
+		{
+			MethodName: "UncordonHost",
+			Handler:    _MachineService_UncordonHost_Handler,
+		},
+		{
+			MethodName: "DrainHost",
+			Handler:    _MachineService_DrainHost_Handler,
+		},
+		{
+			MethodName: "FailHost",
+			Handler:    _MachineService_FailHost_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "machine.proto",
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/correlation"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+)
+
+// RunCrashInjector checks every running machine against the chaos engine's
+// crash faults once per interval until ctx is cancelled.
+func (s *Server) RunCrashInjector(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		s.injectCrashes(ctx, interval)
+	}
+}
+
+func (s *Server) injectCrashes(ctx context.Context, elapsed time.Duration) {
+	machines, err := s.store.ListMachines(ctx)
+	if err != nil {
+		correlation.Logf(ctx, "[crash] list machines failed: %v", err)
+		return
+	}
+	for _, m := range machines {
+		if m.Status != models.StatusRunning {
+			continue
+		}
+		if code, ok := s.chaos.Crash(chaos.Target{Region: m.Region, MachineID: m.ID}, elapsed); ok {
+			s.crashMachine(ctx, m.ID, code)
+		}
+	}
+}
+
+// crashMachine moves a running machine to crashed (or exited for code 0) and
+// applies its restart policy, releasing its host when it is not restarted.
+func (s *Server) crashMachine(ctx context.Context, id string, exitCode int) {
+	s.acquireOpLock(id)
+	defer s.releaseOpLock(id)
+
+	cur, err := s.getMachineCached(ctx, id)
+	if err != nil || cur.Status != models.StatusRunning {
+		return
+	}
+	m := *cur
+
+	event := "machine.crashed"
+	m.Status = models.StatusCrashed
+	if exitCode == 0 {
+		event = "machine.exited"
+		m.Status = models.StatusExited
+	}
+	m.ExitCode = exitCode
+	// A machine that stays down gives up its host, like a stopped one.
+	restarts := m.RestartPolicy.ShouldRestart(exitCode, m.RestartCount)
+	release := !restarts && m.HostID != ""
+	if release {
+		m.HostID = ""
+	}
+	if !s.saveTransition(ctx, &m, event, map[string]interface{}{
+		"status":    m.Status,
+		"exit_code": exitCode,
+	}) {
+		return
+	}
+	machineCrashes.WithLabelValues(m.Region).Inc()
+
+	if !restarts {
+		if release && s.hosts != nil {
+			s.hosts.Release(id)
+			go s.capacityFreed(correlation.Detach(ctx), m.Region)
+		}
+		return
+	}
+	restart := m
+	restart.RestartCount++
+	restart.Status = models.StatusStarting
+	if !s.saveTransition(ctx, &restart, "machine.restarting", map[string]interface{}{
+		"status":        restart.Status,
+		"restart_count": restart.RestartCount,
+		"policy":        restart.RestartPolicy.Policy,
+	}) {
+		return
+	}
+	// The boot goroutine takes the op lock once this function releases it.
+	go s.transitionToRunning(ctx, id, m.Region)
+}
+
+// saveTransition persists m with a bumped version, caches it and publishes
+// event. Nothing is cached or published when the save fails, so m must not
+// be the cached copy.
+func (s *Server) saveTransition(ctx context.Context, m *models.Machine, event string, fields map[string]interface{}) bool {
+	m.Version++
+	m.UpdatedAt = s.now(m)
+	if err := s.store.SaveMachine(ctx, m); err != nil {
+		correlation.Logf(ctx, "[%s] save %s failed: %v", event, m.ID, err)
+		return false
+	}
+	s.cacheMachine(m)
+	s.publishEvent(ctx, m, event, fields)
+	return true
+}
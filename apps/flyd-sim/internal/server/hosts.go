@@ -0,0 +1,251 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/correlation"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/inventory"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+	natsclient "github.com/devghori1264/aerophoenix/flyd-sim/internal/nats"
+	proto "github.com/devghori1264/aerophoenix/flyd-sim/internal/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// hostLostExitCode is the exit code restart policies are evaluated with for a
+// machine whose host failed: losing the host counts as a failure.
+const hostLostExitCode = 137
+
+// Hosts returns a snapshot of every simulated host, or nil without an
+// inventory.
+func (s *Server) Hosts() []inventory.Host {
+	if s.hosts == nil {
+		return nil
+	}
+	return s.hosts.Hosts()
+}
+
+// CordonHost stops new machines being placed on a host. Machines already
+// there keep running.
+func (s *Server) CordonHost(ctx context.Context, req *proto.HostRequest) (*proto.HostResponse, error) {
+	h, err := s.lookupHost(req.HostId)
+	if err != nil {
+		return nil, err
+	}
+	if h.State == inventory.HostFailed {
+		return nil, status.Errorf(codes.FailedPrecondition, "host %s has failed", h.ID)
+	}
+	if h, err = s.setHostState(ctx, h, inventory.HostCordoned, "cordoned", nil); err != nil {
+		return nil, err
+	}
+	return &proto.HostResponse{HostId: h.ID, State: string(h.State)}, nil
+}
+
+// UncordonHost returns a cordoned or failed host to service. A failed host
+// comes back empty.
+func (s *Server) UncordonHost(ctx context.Context, req *proto.HostRequest) (*proto.HostResponse, error) {
+	h, err := s.lookupHost(req.HostId)
+	if err != nil {
+		return nil, err
+	}
+	if h, err = s.setHostState(ctx, h, inventory.HostReady, "uncordoned", nil); err != nil {
+		return nil, err
+	}
+	s.capacityFreed(ctx, h.Region)
+	return &proto.HostResponse{HostId: h.ID, State: string(h.State)}, nil
+}
+
+// DrainHost cordons a host and migrates each of its machines to another host
+// in the same region. Machines that fit nowhere stay on the cordoned host and
+// are reported as stranded.
+func (s *Server) DrainHost(ctx context.Context, req *proto.HostRequest) (*proto.HostResponse, error) {
+	h, err := s.lookupHost(req.HostId)
+	if err != nil {
+		return nil, err
+	}
+	if h.State == inventory.HostFailed {
+		return nil, status.Errorf(codes.FailedPrecondition, "host %s has failed", h.ID)
+	}
+	if h, err = s.setHostState(ctx, h, inventory.HostCordoned, "draining", nil); err != nil {
+		return nil, err
+	}
+
+	res := &proto.HostResponse{HostId: h.ID, State: string(h.State)}
+	for _, id := range s.hosts.MachinesOn(h.ID) {
+		if err := s.drainMachine(ctx, id, h.ID); err != nil {
+			correlation.Logf(ctx, "[hosts] drain %s off %s failed: %v", id, h.ID, err)
+			res.Stranded = append(res.Stranded, id)
+			continue
+		}
+		res.Moved = append(res.Moved, id)
+	}
+	s.publishHostEvent(ctx, h, "drained", map[string]interface{}{
+		"moved":    len(res.Moved),
+		"stranded": res.Stranded,
+	})
+	return res, nil
+}
+
+func (s *Server) drainMachine(ctx context.Context, id, hostID string) error {
+	s.acquireOpLock(id)
+	defer s.releaseOpLock(id)
+
+	cur, err := s.getMachineCached(ctx, id)
+	if err != nil {
+		return err
+	}
+	if cur.HostID != hostID {
+		return nil
+	}
+	if err := s.relocate(ctx, cur, cur.Region, map[string]interface{}{"reason": "drain"}); err != nil {
+		return err
+	}
+	machineActions.WithLabelValues("drain").Inc()
+	return nil
+}
+
+// FailHost marks a host failed and its machines lost. Machines that were
+// running or booting are rescheduled onto another host in their region when
+// their restart policy allows it and there is room; the rest stay lost until
+// started again.
+func (s *Server) FailHost(ctx context.Context, req *proto.HostRequest) (*proto.HostResponse, error) {
+	h, err := s.lookupHost(req.HostId)
+	if err != nil {
+		return nil, err
+	}
+	ids := s.hosts.MachinesOn(h.ID)
+	if h, err = s.setHostState(ctx, h, inventory.HostFailed, "failed", map[string]interface{}{
+		"machines": len(ids),
+	}); err != nil {
+		return nil, err
+	}
+
+	res := &proto.HostResponse{HostId: h.ID, State: string(h.State)}
+	for _, id := range ids {
+		if s.loseMachine(ctx, id, h.ID) {
+			res.Moved = append(res.Moved, id)
+		} else {
+			res.Stranded = append(res.Stranded, id)
+		}
+	}
+	return res, nil
+}
+
+// loseMachine moves a machine on a failed host to lost and reschedules it
+// per its restart policy. It reports whether the machine was rescheduled.
+func (s *Server) loseMachine(ctx context.Context, id, hostID string) bool {
+	s.acquireOpLock(id)
+	defer s.releaseOpLock(id)
+
+	cur, err := s.getMachineCached(ctx, id)
+	if err != nil {
+		correlation.Logf(ctx, "[hosts] load %s failed: %v", id, err)
+		s.hosts.Release(id)
+		return false
+	}
+	if cur.HostID != hostID {
+		return false
+	}
+	active := cur.Status == models.StatusRunning || cur.Status == models.StatusStarting || cur.Status == models.StatusPending
+
+	s.hosts.Release(id)
+	m := *cur
+	m.Status = models.StatusLost
+	m.HostID = ""
+	// The snapshot of a suspended machine went down with its host.
+	m.Snapshot = nil
+	if !s.saveTransition(ctx, &m, "machine.lost", map[string]interface{}{
+		"status":  m.Status,
+		"host_id": hostID,
+	}) {
+		return false
+	}
+	machineCrashes.WithLabelValues(m.Region).Inc()
+
+	if !active || !m.RestartPolicy.ShouldRestart(hostLostExitCode, m.RestartCount) {
+		return false
+	}
+	host, err := s.hosts.Place(id, m.Region, placementRequest(&m))
+	if err != nil {
+		correlation.Logf(ctx, "[hosts] reschedule %s failed: %v", id, err)
+		return false
+	}
+	restart := m
+	restart.HostID = host
+	restart.RestartCount++
+	restart.Status = models.StatusStarting
+	if !s.saveTransition(ctx, &restart, "machine.rescheduled", map[string]interface{}{
+		"status":        restart.Status,
+		"host_id":       host,
+		"from_host":     hostID,
+		"restart_count": restart.RestartCount,
+		"policy":        restart.RestartPolicy.Policy,
+	}) {
+		s.hosts.Release(id)
+		return false
+	}
+	go s.transitionToRunning(correlation.Detach(ctx), id, m.Region)
+	return true
+}
+
+func (s *Server) lookupHost(id string) (inventory.Host, error) {
+	if s.hosts == nil {
+		return inventory.Host{}, status.Error(codes.FailedPrecondition, "no host inventory configured")
+	}
+	if id == "" {
+		return inventory.Host{}, status.Error(codes.InvalidArgument, "host_id required")
+	}
+	h, ok := s.hosts.Host(id)
+	if !ok {
+		return inventory.Host{}, status.Errorf(codes.NotFound, "unknown host %q", id)
+	}
+	return h, nil
+}
+
+func (s *Server) setHostState(ctx context.Context, h inventory.Host, state inventory.HostState, event string, fields map[string]interface{}) (inventory.Host, error) {
+	updated, err := s.hosts.SetState(h.ID, state)
+	if errors.Is(err, inventory.ErrUnknownHost) {
+		return h, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return h, err
+	}
+	ev := map[string]interface{}{"previous_state": h.State}
+	for k, v := range fields {
+		ev[k] = v
+	}
+	s.publishHostEvent(ctx, updated, event, ev)
+	return updated, nil
+}
+
+// publishHostEvent publishes host.<event> on the host's subject with the same
+// envelope as machine events.
+func (s *Server) publishHostEvent(ctx context.Context, h inventory.Host, event string, fields map[string]interface{}) {
+	if s.sink == nil {
+		return
+	}
+	ev := map[string]interface{}{
+		"event":   "host." + event,
+		"host_id": h.ID,
+		"region":  h.Region,
+		"state":   h.State,
+		"time":    s.clock.Now().Unix(),
+	}
+	if rid := correlation.FromContext(ctx); rid != "" {
+		ev["correlation_id"] = rid
+	}
+	for k, v := range fields {
+		ev[k] = v
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	subject := natsclient.HostSubject(h.Region, h.ID, event)
+	if err := s.sink.Publish(ctx, subject, data); err != nil {
+		correlation.Logf(ctx, "[events] publish %s failed: %v", subject, err)
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+	proto "github.com/devghori1264/aerophoenix/flyd-sim/internal/proto"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/storage"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultLeaseTTL is how long a lease lasts when the request sets no TTL.
+const DefaultLeaseTTL = 30 * time.Second
+
+// AcquireLease takes an exclusive lease on a machine. It fails with Aborted
+// while another lease is held.
+func (s *Server) AcquireLease(ctx context.Context, req *proto.AcquireLeaseRequest) (*proto.Lease, error) {
+	if req.Id == "" {
+		return nil, errors.New("id required")
+	}
+	ttl, err := leaseTTL(req.TtlSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	s.acquireOpLock(req.Id)
+	defer s.releaseOpLock(req.Id)
+
+	m, err := s.getMachineCached(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if m.Status == models.StatusTerminated {
+		return nil, status.Errorf(codes.FailedPrecondition, "machine %s is destroyed", req.Id)
+	}
+	cur, err := s.activeLease(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if cur != nil {
+		return nil, status.Errorf(codes.Aborted, "machine %s is already leased until %s", req.Id, cur.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	l := &models.Lease{
+		MachineID:   req.Id,
+		Nonce:       uuid.NewString(),
+		Owner:       req.Owner,
+		Description: req.Description,
+		ExpiresAt:   s.clock.Now().Add(ttl),
+	}
+	if err := s.store.SaveLease(ctx, l, ttl); err != nil {
+		return nil, err
+	}
+	s.publishEvent(ctx, m, "machine.lease_acquired", map[string]interface{}{
+		"owner":      l.Owner,
+		"expires_at": l.ExpiresAt.Unix(),
+	})
+	return leaseProto(l), nil
+}
+
+// RenewLease extends a held lease by a fresh TTL from now.
+func (s *Server) RenewLease(ctx context.Context, req *proto.RenewLeaseRequest) (*proto.Lease, error) {
+	if req.Id == "" {
+		return nil, errors.New("id required")
+	}
+	ttl, err := leaseTTL(req.TtlSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	s.acquireOpLock(req.Id)
+	defer s.releaseOpLock(req.Id)
+
+	l, err := s.heldLease(ctx, req.Id, req.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	renewed := *l
+	renewed.ExpiresAt = s.clock.Now().Add(ttl)
+	if err := s.store.SaveLease(ctx, &renewed, ttl); err != nil {
+		return nil, err
+	}
+	return leaseProto(&renewed), nil
+}
+
+// ReleaseLease gives up a held lease before it expires.
+func (s *Server) ReleaseLease(ctx context.Context, req *proto.ReleaseLeaseRequest) (*proto.ActionResponse, error) {
+	if req.Id == "" {
+		return nil, errors.New("id required")
+	}
+
+	s.acquireOpLock(req.Id)
+	defer s.releaseOpLock(req.Id)
+
+	l, err := s.heldLease(ctx, req.Id, req.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.DeleteLease(ctx, req.Id); err != nil {
+		return nil, err
+	}
+	if m, err := s.getMachineCached(ctx, req.Id); err == nil {
+		s.publishEvent(ctx, m, "machine.lease_released", map[string]interface{}{
+			"owner": l.Owner,
+		})
+	}
+	return &proto.ActionResponse{Result: "ok"}, nil
+}
+
+// GetLease returns the lease held on a machine, or NotFound when there is
+// none.
+func (s *Server) GetLease(ctx context.Context, req *proto.GetRequest) (*proto.Lease, error) {
+	l, err := s.activeLease(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		return nil, status.Errorf(codes.NotFound, "no lease held on machine %s", req.Id)
+	}
+	return leaseProto(l), nil
+}
+
+// checkLease fails with Aborted when a lease is held on id and nonce is not
+// its nonce. The caller holds the machine's op lock.
+func (s *Server) checkLease(ctx context.Context, id, nonce string) error {
+	l, err := s.activeLease(ctx, id)
+	if err != nil {
+		return err
+	}
+	if l != nil && l.Nonce != nonce {
+		return status.Errorf(codes.Aborted, "machine %s is leased; the lease nonce is required", id)
+	}
+	return nil
+}
+
+// heldLease returns the lease on id if nonce matches it.
+func (s *Server) heldLease(ctx context.Context, id, nonce string) (*models.Lease, error) {
+	l, err := s.activeLease(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		return nil, status.Errorf(codes.NotFound, "no lease held on machine %s", id)
+	}
+	if l.Nonce != nonce {
+		return nil, status.Error(codes.Aborted, "lease nonce does not match")
+	}
+	return l, nil
+}
+
+// activeLease returns the lease on id, or nil when there is none or it has
+// expired by the server clock. Badger drops expired leases on its own, but
+// only by the wall clock.
+func (s *Server) activeLease(ctx context.Context, id string) (*models.Lease, error) {
+	l, err := s.store.GetLease(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !s.clock.Now().Before(l.ExpiresAt) {
+		return nil, nil
+	}
+	return l, nil
+}
+
+func leaseTTL(seconds int32) (time.Duration, error) {
+	if seconds < 0 {
+		return 0, status.Error(codes.InvalidArgument, "ttl_seconds must be non-negative")
+	}
+	if seconds == 0 {
+		return DefaultLeaseTTL, nil
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+func leaseProto(l *models.Lease) *proto.Lease {
+	return &proto.Lease{
+		MachineId:   l.MachineID,
+		Nonce:       l.Nonce,
+		Owner:       l.Owner,
+		Description: l.Description,
+		ExpiresAt:   l.ExpiresAt.Unix(),
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/inventory"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// placementRequest is what m asks of the scheduler.
+func placementRequest(m *models.Machine) inventory.Request {
+	class := m.PriorityClass
+	if class == "" {
+		class = models.PriorityStandard
+	}
+	return inventory.Request{
+		Resources:    inventory.Resources{CPUs: m.Guest.CPUs, MemoryMB: m.Guest.MemoryMB},
+		Labels:       m.Labels,
+		Host:         m.Constraints.Host,
+		AntiAffinity: m.Constraints.AntiAffinity,
+		SpreadBy:     m.Constraints.SpreadBy,
+		Priority:     models.PriorityRank(class),
+	}
+}
+
+// placementError converts a scheduling failure to a gRPC status:
+// FailedPrecondition carrying a PreconditionFailure detail with one
+// violation per ruled-out host when constraints are unsatisfiable, and
+// ResourceExhausted when the region is simply full.
+func placementError(err error) error {
+	var unsat *inventory.UnsatisfiableError
+	if !errors.As(err, &unsat) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	st := status.New(codes.FailedPrecondition, err.Error())
+	pf := &errdetails.PreconditionFailure{}
+	for _, v := range unsat.Violations {
+		pf.Violations = append(pf.Violations, &errdetails.PreconditionFailure_Violation{
+			Type:        v.Constraint,
+			Subject:     v.Host,
+			Description: v.Detail,
+		})
+	}
+	if detailed, derr := st.WithDetails(pf); derr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// RestorePlacements re-reserves host capacity for the machines already in the
+// store, so a restarted flyd-sim does not overcommit its hosts. Machines on
+// hosts that are no longer configured keep running but hold no capacity.
+// Machines that were waiting for capacity are queued again in creation order
+// and placed if room has appeared; machines whose preemption was cut short
+// are placed now or stopped.
+func (s *Server) RestorePlacements(ctx context.Context) error {
+	if s.hosts == nil {
+		return nil
+	}
+	machines, err := s.store.ListMachines(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Slice(machines, func(i, j int) bool { return machines[i].CreatedAt.Before(machines[j].CreatedAt) })
+	regions := map[string]bool{}
+	var preempting []string
+	for _, m := range machines {
+		if m.Status == models.StatusPending && m.StatusReason == models.ReasonWaitingForPreemption {
+			preempting = append(preempting, m.ID)
+			continue
+		}
+		if m.Status == models.StatusPending && m.StatusReason == models.ReasonWaitingForCapacity && s.queue != nil {
+			s.enqueue(m, m.CreatedAt)
+			regions[m.Region] = true
+			continue
+		}
+		if m.HostID == "" {
+			continue
+		}
+		if err := s.hosts.Restore(m.ID, m.HostID, placementRequest(m)); err != nil {
+			log.Printf("[placement] restore %s: %v", m.ID, err)
+		}
+	}
+	for _, id := range preempting {
+		s.placePreempting(ctx, id)
+	}
+	for region := range regions {
+		s.capacityFreed(ctx, region)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/correlation"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultPreemptionGrace is how long preempted machines keep running before
+// they are stopped.
+const DefaultPreemptionGrace = 5 * time.Second
+
+var machinePreemptions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "flyd_machine_preemption_total",
+	Help: "Machines stopped to make room for a higher priority class, by region",
+}, []string{"region"})
+
+func init() {
+	prometheus.MustRegister(machinePreemptions)
+}
+
+// preempt publishes machine.preempted for each victim chosen to make room for
+// m and, once the grace period has passed, stops them and places m. The grace
+// period is cut short, and the victims spared, if m is stopped or destroyed
+// in the meantime.
+func (s *Server) preempt(ctx context.Context, m *models.Machine, victims []string) {
+	for _, id := range victims {
+		v, err := s.getMachineCached(ctx, id)
+		if err != nil {
+			continue
+		}
+		s.publishEvent(ctx, v, "machine.preempted", map[string]interface{}{
+			"status":          v.Status,
+			"host_id":         v.HostID,
+			"priority_class":  v.PriorityClass,
+			"preempted_by":    m.ID,
+			"grace_period_ms": s.preemptGrace.Milliseconds(),
+		})
+	}
+	ctx = correlation.Detach(ctx)
+	grace, cancel := context.WithCancel(ctx)
+	s.graces.Store(m.ID, cancel)
+	go func() {
+		err := s.clock.Sleep(grace, s.preemptGrace)
+		s.graces.Delete(m.ID)
+		cancel()
+		if err != nil {
+			s.hosts.CancelEviction(victims...)
+			return
+		}
+		if !s.evictFor(ctx, m.ID, victims) {
+			return
+		}
+		s.placePreempting(ctx, m.ID)
+		s.capacityFreed(ctx, m.Region)
+	}()
+}
+
+// cancelGrace ends the grace period of a preemption made for id, sparing its
+// victims.
+func (s *Server) cancelGrace(id string) {
+	if cancel, ok := s.graces.LoadAndDelete(id); ok {
+		cancel.(context.CancelFunc)()
+	}
+}
+
+// evictFor evicts victims on behalf of the machine id if it still waits for
+// preemption, and otherwise makes them candidates again. It reports whether
+// they were evicted.
+func (s *Server) evictFor(ctx context.Context, id string, victims []string) bool {
+	s.acquireOpLock(id)
+	defer s.releaseOpLock(id)
+
+	cur, err := s.getMachineCached(ctx, id)
+	if err != nil || cur.Status != models.StatusPending || cur.StatusReason != models.ReasonWaitingForPreemption {
+		s.hosts.CancelEviction(victims...)
+		return false
+	}
+	for _, v := range victims {
+		s.evict(ctx, v, id)
+	}
+	return true
+}
+
+// evict stops a preempted machine and releases its host.
+func (s *Server) evict(ctx context.Context, id, by string) {
+	s.acquireOpLock(id)
+	defer s.releaseOpLock(id)
+
+	cur, err := s.getMachineCached(ctx, id)
+	if err != nil {
+		correlation.Logf(ctx, "[preempt] load %s failed: %v", id, err)
+		s.hosts.CancelEviction(id)
+		return
+	}
+	if cur.HostID == "" {
+		// Stopped or destroyed during the grace period.
+		return
+	}
+	m := *cur
+	host := m.HostID
+	m.Status = models.StatusStopped
+	m.StatusReason = models.ReasonPreempted
+	m.HostID = ""
+	m.Snapshot = nil
+	if !s.saveTransition(ctx, &m, "machine.stop", map[string]interface{}{
+		"status":        m.Status,
+		"status_reason": m.StatusReason,
+		"host_id":       host,
+		"preempted_by":  by,
+	}) {
+		s.hosts.CancelEviction(id)
+		return
+	}
+	s.hosts.Release(id)
+	machinePreemptions.WithLabelValues(m.Region).Inc()
+}
+
+// placePreempting places a machine waiting for preemption and boots it. If it
+// still does not fit, it is stopped with reason no_capacity.
+func (s *Server) placePreempting(ctx context.Context, id string) {
+	s.acquireOpLock(id)
+	defer s.releaseOpLock(id)
+
+	cur, err := s.getMachineCached(ctx, id)
+	if err != nil {
+		correlation.Logf(ctx, "[preempt] load %s failed: %v", id, err)
+		return
+	}
+	if cur.Status != models.StatusPending || cur.StatusReason != models.ReasonWaitingForPreemption {
+		return
+	}
+	m := *cur
+	host, err := s.hosts.Place(m.ID, m.Region, placementRequest(&m))
+	if err != nil {
+		correlation.Logf(ctx, "[preempt] place %s failed: %v", id, err)
+		m.Status = models.StatusStopped
+		m.StatusReason = models.ReasonNoCapacity
+		s.saveTransition(ctx, &m, "machine.stop", map[string]interface{}{
+			"status":        m.Status,
+			"status_reason": m.StatusReason,
+		})
+		return
+	}
+	m.HostID = host
+	m.StatusReason = ""
+	if !s.saveTransition(ctx, &m, "machine.placed", map[string]interface{}{
+		"status":  m.Status,
+		"host_id": host,
+	}) {
+		s.hosts.Release(id)
+		return
+	}
+	go s.transitionToRunning(ctx, id, m.Region)
+}
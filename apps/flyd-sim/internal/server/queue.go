@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/correlation"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/inventory"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	queueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "flyd_capacity_queue_depth",
+		Help: "Machines waiting for capacity, by region",
+	}, []string{"region"})
+	queueWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "flyd_capacity_queue_wait_seconds",
+		Help:    "Time machines waited for capacity before being placed, by region",
+		Buckets: prometheus.ExponentialBuckets(0.1, 4, 8),
+	}, []string{"region"})
+)
+
+func init() {
+	prometheus.MustRegister(queueDepth, queueWait)
+}
+
+type queued struct {
+	id string
+	// rank is the machine's priority class rank, as preemption compares it.
+	rank  int
+	since time.Time
+}
+
+// capacityQueue holds the machines waiting for capacity in each region,
+// highest priority class first and in arrival order within a class.
+type capacityQueue struct {
+	mu      sync.Mutex
+	regions map[string][]queued
+	// placing serialises placement passes so they keep the queue order.
+	placing sync.Mutex
+}
+
+func newCapacityQueue() *capacityQueue {
+	return &capacityQueue{regions: make(map[string][]queued)}
+}
+
+func (q *capacityQueue) push(region string, it queued) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.regions[region]
+	i := sort.Search(len(items), func(i int) bool { return items[i].rank < it.rank })
+	items = append(items, queued{})
+	copy(items[i+1:], items[i:])
+	items[i] = it
+	q.regions[region] = items
+	queueDepth.WithLabelValues(region).Set(float64(len(items)))
+}
+
+func (q *capacityQueue) remove(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for region, items := range q.regions {
+		for i, it := range items {
+			if it.id == id {
+				q.regions[region] = append(items[:i:i], items[i+1:]...)
+				queueDepth.WithLabelValues(region).Set(float64(len(items) - 1))
+				return
+			}
+		}
+	}
+}
+
+// move requeues id at the back of region's queue for its class. It keeps
+// the time it has been waiting since.
+func (q *capacityQueue) move(id, region string) {
+	q.mu.Lock()
+	var it queued
+	found := false
+	for from, items := range q.regions {
+		for i, cand := range items {
+			if cand.id == id {
+				it, found = cand, true
+				q.regions[from] = append(items[:i:i], items[i+1:]...)
+				queueDepth.WithLabelValues(from).Set(float64(len(items) - 1))
+				break
+			}
+		}
+	}
+	q.mu.Unlock()
+	if found {
+		q.push(region, it)
+	}
+}
+
+func (q *capacityQueue) waiting(region string) []queued {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]queued(nil), q.regions[region]...)
+}
+
+func (q *capacityQueue) depth(region string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.regions[region])
+}
+
+// queueDepthOf is how many machines wait for capacity in region.
+func (s *Server) queueDepthOf(region string) int {
+	if s.queue == nil {
+		return 0
+	}
+	return s.queue.depth(region)
+}
+
+// enqueue records that m, already saved as pending, has waited for capacity
+// since then.
+func (s *Server) enqueue(m *models.Machine, since time.Time) {
+	s.queue.push(m.Region, queued{id: m.ID, rank: placementRequest(m).Priority, since: since})
+}
+
+// dequeue drops id from the capacity queue, e.g. once it is started, stopped
+// or destroyed by hand.
+func (s *Server) dequeue(id string) {
+	if s.queue != nil {
+		s.queue.remove(id)
+	}
+}
+
+// capacityFreed places the machines waiting in region, in queue order, after
+// capacity there was released. It stops at the first machine that still does
+// not fit so later, smaller machines cannot starve it; machines whose
+// constraints cannot be met are skipped and stay queued.
+func (s *Server) capacityFreed(ctx context.Context, region string) {
+	if s.queue == nil || s.hosts == nil {
+		return
+	}
+	s.queue.placing.Lock()
+	defer s.queue.placing.Unlock()
+	for _, it := range s.queue.waiting(region) {
+		if full := s.placeQueued(ctx, it); full {
+			return
+		}
+	}
+}
+
+// placeQueued places one queued machine and boots it. It reports whether the
+// region was too full to place it.
+func (s *Server) placeQueued(ctx context.Context, it queued) bool {
+	s.acquireOpLock(it.id)
+	defer s.releaseOpLock(it.id)
+
+	cur, err := s.getMachineCached(ctx, it.id)
+	if err != nil {
+		correlation.Logf(ctx, "[queue] load %s failed: %v", it.id, err)
+		return false
+	}
+	if cur.Status != models.StatusPending || cur.StatusReason != models.ReasonWaitingForCapacity {
+		s.queue.remove(it.id)
+		return false
+	}
+	m := *cur
+	host, err := s.hosts.Place(m.ID, m.Region, placementRequest(&m))
+	if err != nil {
+		var unsat *inventory.UnsatisfiableError
+		return !errors.As(err, &unsat)
+	}
+	waited := s.clock.Now().Sub(it.since)
+	m.HostID = host
+	m.StatusReason = ""
+	if !s.saveTransition(ctx, &m, "machine.placed", map[string]interface{}{
+		"status":    m.Status,
+		"host_id":   host,
+		"waited_ms": waited.Milliseconds(),
+	}) {
+		s.hosts.Release(m.ID)
+		return true
+	}
+	s.queue.remove(m.ID)
+	queueWait.WithLabelValues(m.Region).Observe(waited.Seconds())
+	go s.transitionToRunning(correlation.Detach(ctx), m.ID, m.Region)
+	return false
+}
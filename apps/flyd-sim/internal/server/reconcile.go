@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/correlation"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+)
+
+// unreachable reports whether m's host is partitioned off from callers.
+func (s *Server) unreachable(m *models.Machine) bool {
+	return s.chaos.Partitioned(chaos.Target{Region: m.Region, MachineID: m.ID})
+}
+
+// onChaosEvent wakes WaitMachine callers when a partition comes or goes,
+// since that changes what machines report without touching their state, and
+// reconciles the machines behind a partition once it heals.
+func (s *Server) onChaosEvent(ev chaos.Event) {
+	if ev.Fault.Kind != chaos.KindPartition {
+		return
+	}
+	switch ev.Type {
+	case chaos.EventApplied:
+		s.wake()
+	case chaos.EventHealed:
+		s.wake()
+		s.reconcile(context.Background(), ev.Fault.Scope)
+	}
+}
+
+// reconcile publishes machine.reconciled with the true state of every
+// machine in scope that is reachable again, since the events it produced
+// while partitioned never got out.
+func (s *Server) reconcile(ctx context.Context, scope chaos.Scope) {
+	machines, err := s.store.ListMachines(ctx)
+	if err != nil {
+		correlation.Logf(ctx, "[reconcile] list machines failed: %v", err)
+		return
+	}
+	for _, m := range machines {
+		if m.Region != scope.Region || (scope.MachineID != "" && m.ID != scope.MachineID) {
+			continue
+		}
+		if s.unreachable(m) {
+			continue
+		}
+		s.publishEvent(ctx, m, "machine.reconciled", map[string]interface{}{
+			"status":        m.Status,
+			"version":       m.Version,
+			"exit_code":     m.ExitCode,
+			"restart_count": m.RestartCount,
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/correlation"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+	proto "github.com/devghori1264/aerophoenix/flyd-sim/internal/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// checkRegion rejects regions missing from the catalog. Without an inventory
+// any region is accepted.
+func (s *Server) checkRegion(region string) error {
+	if s.hosts != nil && !s.hosts.HasRegion(region) {
+		return status.Errorf(codes.InvalidArgument, "unknown region %q", region)
+	}
+	return nil
+}
+
+// ListRegions returns the region catalog with capacity and the live chaos
+// status of each region. It is empty when no inventory is configured.
+func (s *Server) ListRegions(ctx context.Context, _ *proto.ListRegionsRequest) (*proto.ListRegionsResponse, error) {
+	res := &proto.ListRegionsResponse{}
+	if s.hosts == nil {
+		return res, nil
+	}
+	for _, r := range s.hosts.Regions() {
+		res.Regions = append(res.Regions, &proto.Region{
+			Code:        r.Code,
+			Name:        r.Name,
+			Latitude:    r.Latitude,
+			Longitude:   r.Longitude,
+			Hosts:       int32(r.HostCount),
+			Machines:    int32(r.Machines),
+			Capacity:    &proto.Capacity{Cpus: int32(r.Capacity.CPUs), MemoryMb: int32(r.Capacity.MemoryMB)},
+			Used:        &proto.Capacity{Cpus: int32(r.Used.CPUs), MemoryMb: int32(r.Used.MemoryMB)},
+			Partitioned: s.chaos.IsPartitioned(r.Code),
+			LatencyMs:   int32(s.chaos.Latency(r.Code) / time.Millisecond),
+			Queued:      int32(s.queueDepthOf(r.Code)),
+		})
+	}
+	return res, nil
+}
+
+// MigrateMachine moves a machine to another region, placing it on a host
+// there when an inventory is configured. A running machine reboots in the
+// target region; any other machine keeps its status.
+func (s *Server) MigrateMachine(ctx context.Context, req *proto.MigrateRequest) (*proto.ActionResponse, error) {
+	if req.Id == "" {
+		return nil, errors.New("id required")
+	}
+	if req.TargetRegion == "" {
+		return nil, status.Error(codes.InvalidArgument, "target_region required")
+	}
+	if err := s.checkRegion(req.TargetRegion); err != nil {
+		return nil, err
+	}
+
+	s.acquireOpLock(req.Id)
+	defer s.releaseOpLock(req.Id)
+
+	cur, err := s.getMachineCached(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkLease(ctx, req.Id, req.LeaseNonce); err != nil {
+		return nil, err
+	}
+	switch {
+	case cur.Status == models.StatusTerminated:
+		return nil, status.Errorf(codes.FailedPrecondition, "machine %s is destroyed", req.Id)
+	case cur.Status == models.StatusPending && cur.StatusReason == models.ReasonWaitingForPreemption:
+		return nil, status.Errorf(codes.FailedPrecondition, "machine %s is waiting for preemption in %s", req.Id, cur.Region)
+	}
+	if cur.Region == req.TargetRegion {
+		return &proto.ActionResponse{Result: "already in region"}, nil
+	}
+	if err := s.relocate(ctx, cur, req.TargetRegion, nil); err != nil {
+		return nil, err
+	}
+	machineActions.WithLabelValues("migrate").Inc()
+	return &proto.ActionResponse{Result: "ok"}, nil
+}
+
+// relocate moves cur to another host in region, publishing machine.migrated
+// with fields added. A running machine reboots there after the transfer; any
+// other machine keeps its status. A machine holding no host, such as a
+// stopped, lost or queued one, only changes region: it is placed when it next
+// starts. The caller holds the machine's op lock.
+func (s *Server) relocate(ctx context.Context, cur *models.Machine, region string, fields map[string]interface{}) error {
+	m := *cur
+	from, fromHost := m.Region, m.HostID
+	m.Region = region
+	placed := s.hosts != nil && fromHost != ""
+	if placed {
+		host, err := s.hosts.Move(m.ID, m.Region, placementRequest(&m))
+		if err != nil {
+			return placementError(err)
+		}
+		m.HostID = host
+	}
+	boot := m.Status == models.StatusRunning
+	var transfer time.Duration
+	if boot {
+		m.Status = models.StatusStarting
+		transfer = migrationTime(s.rtt(from, m.Region), m.Guest.MemoryMB)
+	}
+	m.Version++
+	m.UpdatedAt = s.now(&m)
+
+	if err := s.store.SaveMachine(ctx, &m); err != nil {
+		if placed {
+			s.hosts.Release(m.ID)
+			if rerr := s.hosts.Restore(m.ID, fromHost, placementRequest(cur)); rerr != nil {
+				correlation.Logf(ctx, "[migrate] restore placement of %s failed: %v", m.ID, rerr)
+			}
+		}
+		return err
+	}
+	s.cacheMachine(&m)
+	if s.queue != nil && m.Status == models.StatusPending && m.StatusReason == models.ReasonWaitingForCapacity {
+		// It waits for capacity in its new region instead.
+		s.queue.move(m.ID, m.Region)
+		go s.capacityFreed(correlation.Detach(ctx), m.Region)
+	}
+
+	ev := map[string]interface{}{
+		"status":      m.Status,
+		"from_region": from,
+		"from_host":   fromHost,
+		"host_id":     m.HostID,
+		"transfer_ms": transfer.Milliseconds(),
+	}
+	for k, v := range fields {
+		ev[k] = v
+	}
+	s.publishEvent(ctx, &m, "machine.migrated", ev)
+	if fromHost != "" {
+		go s.capacityFreed(correlation.Detach(ctx), from)
+	}
+	if boot {
+		go func(ctx context.Context) {
+			if err := s.clock.Sleep(ctx, transfer); err != nil {
+				return
+			}
+			s.transitionToRunning(ctx, m.ID, m.Region)
+		}(correlation.Detach(ctx))
+	}
+	return nil
+}
+
+// migrationTime models moving a running machine: a few round trips to set up
+// the transfer, then one per 128 MB window of memory copied.
+func migrationTime(rtt time.Duration, memoryMB int) time.Duration {
+	return rtt * time.Duration(4+memoryMB/128)
+}
+
+// rtt is the round trip between two regions, zero without an inventory.
+func (s *Server) rtt(from, to string) time.Duration {
+	if s.hosts == nil {
+		return 0
+	}
+	return s.hosts.RTT(from, to)
+}
+
+// RTTMatrix returns the catalog's region codes and the RTT in milliseconds
+// between each pair; both are empty without an inventory.
+func (s *Server) RTTMatrix() ([]string, [][]float64) {
+	if s.hosts == nil {
+		return nil, nil
+	}
+	return s.hosts.RTTMatrix()
+}
+
+// RunLatencySampler publishes a machine.latency event for every reachable
+// running machine once per interval until ctx is cancelled. Each sample is
+// the RTT from origin to the machine's region plus the delay chaos faults
+// add there, with ±10% noise; an empty origin samples from the machine's own
+// region.
+func (s *Server) RunLatencySampler(ctx context.Context, origin string, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		machines, err := s.store.ListMachines(ctx)
+		if err != nil {
+			correlation.Logf(ctx, "[latency] list machines failed: %v", err)
+			continue
+		}
+		for _, m := range machines {
+			if m.Status != models.StatusRunning || s.unreachable(m) {
+				continue
+			}
+			from := origin
+			if from == "" {
+				from = m.Region
+			}
+			d := s.rtt(from, m.Region) + s.chaos.SampleDelay(chaos.Target{Region: m.Region, MachineID: m.ID})
+			ms := float64(d) / float64(time.Millisecond) * (0.9 + 0.2*s.chaos.Float64())
+			s.publishEvent(ctx, m, "machine.latency", map[string]interface{}{
+				"latency_ms":  math.Round(ms*10) / 10,
+				"from_region": from,
+			})
+		}
+	}
+}
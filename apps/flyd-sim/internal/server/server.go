@@ -5,9 +5,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/clock"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/correlation"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/events"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/inventory"
 	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
 	natsclient "github.com/devghori1264/aerophoenix/flyd-sim/internal/nats"
 	proto "github.com/devghori1264/aerophoenix/flyd-sim/internal/proto"
@@ -16,6 +22,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -27,27 +35,98 @@ var (
 		Name: "flyd_machine_action_total",
 		Help: "Counts of actions performed on machines",
 	}, []string{"action"})
+	machineCrashes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "flyd_machine_crash_total",
+		Help: "Machines that exited on their own, by region",
+	}, []string{"region"})
 )
 
 func init() {
-	prometheus.MustRegister(machineCreated, machineActions)
+	prometheus.MustRegister(machineCreated, machineActions, machineCrashes)
 }
 
 type Server struct {
 	proto.UnimplementedMachineServiceServer
-	store     storage.Store
-	mu        sync.RWMutex
-	cache     map[string]*models.Machine
-	opMu      sync.Map
-	publisher *natsclient.Publisher
+	store storage.Store
+	mu    sync.RWMutex
+	cache map[string]*models.Machine
+	opMu  sync.Map
+	sink  events.EventSink
+	chaos *chaos.Engine
+	clock clock.Clock
+	hosts *inventory.Inventory
+	queue *capacityQueue
+	// preemptGrace is how long a preempted machine keeps running after
+	// machine.preempted before it is stopped.
+	preemptGrace time.Duration
+	// graces holds the cancel func of each pending preemption's grace
+	// period, by preempting machine ID.
+	graces sync.Map
+	// changed is closed and replaced whenever a machine's cached state
+	// changes, waking WaitMachine callers.
+	changed chan struct{}
 }
 
-func New(store storage.Store, publisher *natsclient.Publisher) *Server {
-	return &Server{
-		store:     store,
-		cache:     make(map[string]*models.Machine),
-		publisher: publisher,
+// Option configures optional Server dependencies.
+type Option func(*Server)
+
+// WithChaos shares a chaos engine with the server so background transitions
+// honour the same faults as the gRPC and HTTP entry points.
+func WithChaos(e *chaos.Engine) Option {
+	return func(s *Server) { s.chaos = e }
+}
+
+// WithClock sets the server's time source; it defaults to the wall clock.
+func WithClock(c clock.Clock) Option {
+	return func(s *Server) { s.clock = c }
+}
+
+// WithInventory places machines on the simulated hosts of inv. Without it
+// machines are not placed anywhere and capacity is unlimited.
+func WithInventory(inv *inventory.Inventory) Option {
+	return func(s *Server) { s.hosts = inv }
+}
+
+// WithCapacityQueue makes CreateMachine queue machines whose region is full
+// as pending with reason waiting_for_capacity, instead of rejecting them.
+// They are placed as stops, destroys and migrations free capacity.
+func WithCapacityQueue() Option {
+	return func(s *Server) { s.queue = newCapacityQueue() }
+}
+
+// WithPreemptionGrace sets how long preempted machines keep running after
+// machine.preempted is published; it defaults to DefaultPreemptionGrace.
+func WithPreemptionGrace(d time.Duration) Option {
+	return func(s *Server) { s.preemptGrace = d }
+}
+
+// New returns a Server persisting to store and emitting events to sink. A nil
+// sink disables event publishing.
+func New(store storage.Store, sink events.EventSink, opts ...Option) *Server {
+	s := &Server{
+		store:        store,
+		cache:        make(map[string]*models.Machine),
+		changed:      make(chan struct{}),
+		sink:         sink,
+		chaos:        chaos.NewEngine(),
+		clock:        clock.Real(),
+		preemptGrace: DefaultPreemptionGrace,
+	}
+	for _, o := range opts {
+		o(s)
 	}
+	s.chaos.AddNotifier(s.onChaosEvent)
+	return s
+}
+
+// MachineRegion returns the region of machine id. It is the chaos layer's
+// RegionResolver.
+func (s *Server) MachineRegion(ctx context.Context, id string) (string, error) {
+	m, err := s.getMachineCached(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return m.Region, nil
 }
 
 func (s *Server) RegisterGRPC(gs *grpc.Server) {
@@ -65,35 +144,110 @@ func (s *Server) CreateMachine(ctx context.Context, req *proto.CreateRequest) (*
 	if req.Region == "" {
 		return nil, errors.New("region required")
 	}
+	if err := s.checkRegion(req.Region); err != nil {
+		return nil, err
+	}
 
 	m := &models.Machine{
-		ID:        uuid.NewString(),
-		Name:      req.Name,
-		Region:    req.Region,
-		Status:    "pending",
-		Version:   1,
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
-		Metadata:  map[string]string{},
+		ID:            uuid.NewString(),
+		Name:          req.Name,
+		Region:        req.Region,
+		Status:        models.StatusPending,
+		Version:       1,
+		Metadata:      map[string]string{},
+		PriorityClass: req.PriorityClass,
+	}
+	if m.PriorityClass == "" {
+		m.PriorityClass = models.PriorityStandard
+	}
+	if models.PriorityRank(m.PriorityClass) < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown priority class %q", m.PriorityClass)
+	}
+	m.CreatedAt = s.now(m)
+	m.UpdatedAt = m.CreatedAt
+	if rp := req.GetRestartPolicy(); rp != nil {
+		m.RestartPolicy = models.RestartPolicy{Policy: rp.Policy, MaxRetries: int(rp.MaxRetries)}
+	}
+	if m.RestartPolicy.Policy == "" {
+		m.RestartPolicy.Policy = models.RestartNo
+	}
+	switch m.RestartPolicy.Policy {
+	case models.RestartNo, models.RestartOnFailure, models.RestartAlways:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown restart policy %q", m.RestartPolicy.Policy)
+	}
+	if m.RestartPolicy.MaxRetries < 0 {
+		return nil, status.Error(codes.InvalidArgument, "max_retries must be non-negative")
+	}
+	m.Guest = models.Guest{CPUs: models.DefaultCPUs, MemoryMB: models.DefaultMemoryMB}
+	if g := req.GetGuest(); g != nil {
+		if g.Cpus < 0 || g.MemoryMb < 0 {
+			return nil, status.Error(codes.InvalidArgument, "guest cpus and memory_mb must be non-negative")
+		}
+		if g.Cpus > 0 {
+			m.Guest.CPUs = int(g.Cpus)
+		}
+		if g.MemoryMb > 0 {
+			m.Guest.MemoryMB = int(g.MemoryMb)
+		}
+	}
+	m.Labels = req.GetLabels()
+	if c := req.GetConstraints(); c != nil {
+		m.Constraints = models.Constraints{Host: c.Host, AntiAffinity: c.AntiAffinity, SpreadBy: c.SpreadBy}
+	}
+	if k := m.Constraints.SpreadBy; k != "" {
+		if _, ok := m.Labels[k]; !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "spread_by label %q missing from labels", k)
+		}
+	}
+
+	var victims []string
+	if s.hosts != nil {
+		host, err := s.hosts.Place(m.ID, m.Region, placementRequest(m))
+		if errors.Is(err, inventory.ErrNoCapacity) {
+			if _, victims, _ = s.hosts.Victims(m.ID, m.Region, placementRequest(m)); victims != nil {
+				m.StatusReason, err = models.ReasonWaitingForPreemption, nil
+			}
+		}
+		switch {
+		case m.StatusReason != "":
+		case err == nil:
+			m.HostID = host
+		case s.queue != nil && errors.Is(err, inventory.ErrNoCapacity):
+			m.StatusReason = models.ReasonWaitingForCapacity
+		default:
+			return nil, placementError(err)
+		}
 	}
 
 	if err := s.store.SaveMachine(ctx, m); err != nil {
+		if s.hosts != nil {
+			s.hosts.Release(m.ID)
+			s.hosts.CancelEviction(victims...)
+		}
 		return nil, fmt.Errorf("save: %w", err)
 	}
 
 	machineCreated.Inc()
 	machineActions.WithLabelValues("create").Inc()
 
-	s.publishEvent(ctx, map[string]interface{}{
-		"event":  "machine.created",
-		"id":     m.ID,
-		"name":   m.Name,
-		"region": m.Region,
-		"time":   time.Now().Unix(),
-	})
+	fields := map[string]interface{}{
+		"name": m.Name,
+	}
+	if m.StatusReason != "" {
+		fields["status_reason"] = m.StatusReason
+	}
+	s.publishEvent(ctx, m, "machine.created", fields)
 
-	go s.transitionToRunning(m.ID)
-	return &proto.CreateResponse{Id: m.ID, Status: m.Status}, nil
+	switch m.StatusReason {
+	case models.ReasonWaitingForCapacity:
+		s.enqueue(m, s.clock.Now())
+	case models.ReasonWaitingForPreemption:
+		s.preempt(ctx, m, victims)
+	default:
+		go s.transitionToRunning(correlation.Detach(ctx), m.ID, m.Region)
+	}
+	return &proto.CreateResponse{Id: m.ID, Status: m.Status, StatusReason: m.StatusReason}, nil
 }
 
 func (s *Server) GetMachine(ctx context.Context, req *proto.GetRequest) (*proto.GetResponse, error) {
@@ -101,100 +255,300 @@ func (s *Server) GetMachine(ctx context.Context, req *proto.GetRequest) (*proto.
 	if err != nil {
 		return nil, err
 	}
-	return &proto.GetResponse{Id: m.ID, Status: m.Status, Region: m.Region}, nil
+	return s.snapshot(m), nil
+}
+
+// snapshot is m as reported to callers.
+func (s *Server) snapshot(m *models.Machine) *proto.GetResponse {
+	res := &proto.GetResponse{
+		Id:           m.ID,
+		Status:       m.Status,
+		Region:       m.Region,
+		ExitCode:     int32(m.ExitCode),
+		RestartCount: int32(m.RestartCount),
+		RestartPolicy: &proto.RestartPolicy{
+			Policy:     m.RestartPolicy.Policy,
+			MaxRetries: int32(m.RestartPolicy.MaxRetries),
+		},
+		HostId:        m.HostID,
+		Guest:         &proto.Guest{Cpus: int32(m.Guest.CPUs), MemoryMb: int32(m.Guest.MemoryMB)},
+		Labels:        m.Labels,
+		StatusReason:  m.StatusReason,
+		PriorityClass: m.PriorityClass,
+		Constraints: &proto.Constraints{
+			Host:         m.Constraints.Host,
+			AntiAffinity: m.Constraints.AntiAffinity,
+			SpreadBy:     m.Constraints.SpreadBy,
+		},
+	}
+	if m.Snapshot != nil {
+		res.Snapshot = &proto.Snapshot{MemoryMb: int32(m.Snapshot.MemoryMB), TakenAt: m.Snapshot.TakenAt.Unix()}
+	}
+	if s.hosts != nil && m.HostID != "" {
+		if h, ok := s.hosts.Host(m.HostID); ok {
+			res.Zone = h.Zone
+		}
+	}
+	// The host cannot be asked, so its last known state is withheld.
+	if s.unreachable(m) {
+		res.Status = models.StatusUnreachable
+		res.ExitCode = 0
+		res.RestartCount = 0
+	}
+	return res
 }
 
 func (s *Server) StartMachine(ctx context.Context, req *proto.ActionRequest) (*proto.ActionResponse, error) {
 	if req.Id == "" {
 		return nil, errors.New("id required")
 	}
-	return s.performAction(ctx, req.Id, "start")
+	return s.performAction(ctx, req.Id, req.LeaseNonce, "start")
 }
 
 func (s *Server) StopMachine(ctx context.Context, req *proto.ActionRequest) (*proto.ActionResponse, error) {
 	if req.Id == "" {
 		return nil, errors.New("id required")
 	}
-	return s.performAction(ctx, req.Id, "stop")
+	return s.performAction(ctx, req.Id, req.LeaseNonce, "stop")
+}
+
+// DestroyMachine terminates a machine. Its record is kept with status
+// terminated, and its host capacity goes to machines waiting for it.
+func (s *Server) DestroyMachine(ctx context.Context, req *proto.ActionRequest) (*proto.ActionResponse, error) {
+	if req.Id == "" {
+		return nil, errors.New("id required")
+	}
+	return s.performAction(ctx, req.Id, req.LeaseNonce, "destroy")
+}
+
+// RestartMachine cold boots a machine again: it goes to starting and runs
+// once booted. A stopped or lost machine is placed again first.
+func (s *Server) RestartMachine(ctx context.Context, req *proto.ActionRequest) (*proto.ActionResponse, error) {
+	if req.Id == "" {
+		return nil, errors.New("id required")
+	}
+	return s.performAction(ctx, req.Id, req.LeaseNonce, "restart")
+}
+
+// SuspendMachine snapshots a running machine's memory and pauses it. It keeps
+// its host so it can be resumed without waiting for capacity.
+func (s *Server) SuspendMachine(ctx context.Context, req *proto.ActionRequest) (*proto.ActionResponse, error) {
+	if req.Id == "" {
+		return nil, errors.New("id required")
+	}
+	return s.performAction(ctx, req.Id, req.LeaseNonce, "suspend")
+}
+
+// ResumeMachine boots a suspended machine from its snapshot.
+func (s *Server) ResumeMachine(ctx context.Context, req *proto.ActionRequest) (*proto.ActionResponse, error) {
+	if req.Id == "" {
+		return nil, errors.New("id required")
+	}
+	return s.performAction(ctx, req.Id, req.LeaseNonce, "resume")
 }
 
-func (s *Server) performAction(ctx context.Context, id, action string) (*proto.ActionResponse, error) {
+// performAction applies action to a machine. nonce must match its lease
+// while one is held.
+func (s *Server) performAction(ctx context.Context, id, nonce, action string) (*proto.ActionResponse, error) {
+	res, freed, err := s.applyAction(ctx, id, nonce, action)
+	if freed != "" {
+		// Placed outside the op lock, which queued machines need too.
+		go s.capacityFreed(correlation.Detach(ctx), freed)
+	}
+	return res, err
+}
+
+// applyAction performs action on a machine and returns the region whose
+// capacity it released, if any.
+func (s *Server) applyAction(ctx context.Context, id, nonce, action string) (*proto.ActionResponse, string, error) {
 	s.acquireOpLock(id)
 	defer s.releaseOpLock(id)
 
-	m, err := s.getMachineCached(ctx, id)
+	cur, err := s.getMachineCached(ctx, id)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if err := s.checkLease(ctx, id, nonce); err != nil {
+		return nil, "", err
+	}
+	// Work on a copy so a failed save leaves the cached machine untouched.
+	m := *cur
+	if m.Status == models.StatusTerminated {
+		if action == "destroy" {
+			return &proto.ActionResponse{Result: "already destroyed"}, "", nil
+		}
+		return nil, "", status.Errorf(codes.FailedPrecondition, "machine %s is destroyed", id)
 	}
 
+	fields := map[string]interface{}{}
+	// boot is set for actions that leave the machine starting.
+	boot := false
 	switch action {
 	case "start":
-		if m.Status == "running" {
-			return &proto.ActionResponse{Result: "already running"}, nil
+		if m.Status == models.StatusRunning {
+			return &proto.ActionResponse{Result: "already running"}, "", nil
+		}
+		if err := s.placeAgain(&m); err != nil {
+			return nil, "", err
+		}
+		m.Status = models.StatusRunning
+		m.ExitCode = 0
+		m.RestartCount = 0
+	case "restart":
+		if m.Status == models.StatusPending || m.Status == models.StatusStarting {
+			return nil, "", status.Errorf(codes.FailedPrecondition, "machine %s is %s", id, m.Status)
+		}
+		if err := s.placeAgain(&m); err != nil {
+			return nil, "", err
 		}
-		m.Status = "running"
+		m.Status = models.StatusStarting
+		m.ExitCode = 0
+		m.RestartCount = 0
+		boot = true
+	case "suspend":
+		if m.Status == models.StatusSuspended {
+			return &proto.ActionResponse{Result: "already suspended"}, "", nil
+		}
+		if m.Status != models.StatusRunning {
+			return nil, "", status.Errorf(codes.FailedPrecondition, "machine %s is %s; only a running machine can be suspended", id, m.Status)
+		}
+		m.Status = models.StatusSuspended
+		m.Snapshot = &models.Snapshot{MemoryMB: m.Guest.MemoryMB, TakenAt: s.now(&m)}
+		fields["snapshot_mb"] = m.Snapshot.MemoryMB
+	case "resume":
+		if m.Status != models.StatusSuspended || m.Snapshot == nil {
+			return nil, "", status.Errorf(codes.FailedPrecondition, "machine %s is %s, not suspended", id, m.Status)
+		}
+		m.Status = models.StatusStarting
+		fields["snapshot_mb"] = m.Snapshot.MemoryMB
+		boot = true
 	case "stop":
-		if m.Status == "stopped" {
-			return &proto.ActionResponse{Result: "already stopped"}, nil
+		if m.Status == models.StatusStopped {
+			return &proto.ActionResponse{Result: "already stopped"}, "", nil
 		}
-		m.Status = "stopped"
+		m.Status = models.StatusStopped
+	case "destroy":
+		m.Status = models.StatusTerminated
 	default:
-		return nil, errors.New("unknown action")
+		return nil, "", errors.New("unknown action")
+	}
+	m.StatusReason = ""
+	// Any action but suspend and resume discards the memory snapshot.
+	if action != "suspend" && action != "resume" {
+		m.Snapshot = nil
+	}
+	// Stopped and destroyed machines give up their host.
+	release := (action == "stop" || action == "destroy") && m.HostID != ""
+	if release {
+		m.HostID = ""
 	}
 
 	m.Version++
-	m.UpdatedAt = time.Now().UTC()
+	m.UpdatedAt = s.now(&m)
 
-	if err := s.store.SaveMachine(ctx, m); err != nil {
-		return nil, err
+	if err := s.store.SaveMachine(ctx, &m); err != nil {
+		if s.hosts != nil && cur.HostID == "" && m.HostID != "" {
+			s.hosts.Release(m.ID)
+		}
+		return nil, "", err
 	}
 
-	s.mu.Lock()
-	s.cache[m.ID] = m
-	s.mu.Unlock()
+	s.cacheMachine(&m)
+	s.dequeue(m.ID)
+	// The machine no longer waits for a preemption.
+	s.cancelGrace(m.ID)
+	var freed string
+	if release && s.hosts != nil {
+		s.hosts.Release(m.ID)
+		freed = m.Region
+	}
+	if action == "destroy" {
+		if err := s.store.DeleteLease(ctx, m.ID); err != nil {
+			correlation.Logf(ctx, "[destroy] drop lease of %s failed: %v", m.ID, err)
+		}
+	}
 
 	machineActions.WithLabelValues(action).Inc()
-	s.publishEvent(ctx, map[string]interface{}{
-		"event":  fmt.Sprintf("machine.%s", action),
-		"id":     m.ID,
-		"status": m.Status,
-		"time":   time.Now().Unix(),
-	})
+	fields["status"] = m.Status
+	s.publishEvent(ctx, &m, fmt.Sprintf("machine.%s", action), fields)
+	if boot {
+		// The boot goroutine takes the op lock once this function releases it.
+		go s.transitionToRunning(correlation.Detach(ctx), m.ID, m.Region)
+	}
 
-	return &proto.ActionResponse{Result: "ok"}, nil
+	return &proto.ActionResponse{Result: "ok"}, freed, nil
 }
 
-func (s *Server) transitionToRunning(id string) {
+// placeAgain places m if it has no host, as for a lost, stopped or queued
+// machine.
+func (s *Server) placeAgain(m *models.Machine) error {
+	if s.hosts == nil || m.HostID != "" {
+		return nil
+	}
+	host, err := s.hosts.Place(m.ID, m.Region, placementRequest(m))
+	if err != nil {
+		return placementError(err)
+	}
+	m.HostID = host
+	return nil
+}
+
+const (
+	// bootTime is how long a machine takes to boot.
+	bootTime = 500 * time.Millisecond
+	// resumeTime is how long a suspended machine takes to resume from its
+	// snapshot.
+	resumeTime = 100 * time.Millisecond
+)
+
+// transitionToRunning completes machine boot in the background for machines
+// that are pending or starting; one stopped in the meantime stays stopped. A
+// machine with a memory snapshot resumes from it in resumeTime instead.
+// ctx carries only the originating request's correlation ID, not its
+// deadline. Boot carries on inside a partition, slowed only by latency faults.
+func (s *Server) transitionToRunning(ctx context.Context, id, region string) {
+	if err := s.chaos.Delay(ctx, chaos.Target{Region: region, MachineID: id}); err != nil {
+		return
+	}
+
 	s.acquireOpLock(id)
 	defer s.releaseOpLock(id)
 
-	ctx := context.Background()
 	m, err := s.store.GetMachine(ctx, id)
 	if err != nil {
+		correlation.Logf(ctx, "[transition] load %s failed: %v", id, err)
 		return
 	}
 
-	if m.Status == "terminated" {
+	if m.Status != models.StatusPending && m.Status != models.StatusStarting {
 		return
 	}
 
-	time.Sleep(500 * time.Millisecond)
-	m.Status = "running"
+	d, fields := bootTime, map[string]interface{}{"status": models.StatusRunning}
+	if m.Snapshot != nil {
+		d = resumeTime
+		fields["resumed_from_snapshot"] = true
+	}
+	if err := s.clock.Sleep(ctx, d); err != nil {
+		return
+	}
+	m.Status = models.StatusRunning
+	m.Snapshot = nil
 	m.Version++
-	m.UpdatedAt = time.Now().UTC()
+	m.UpdatedAt = s.now(m)
 
-	if err := s.store.SaveMachine(ctx, m); err == nil {
-		s.mu.Lock()
-		s.cache[m.ID] = m
-		s.mu.Unlock()
+	if err := s.store.SaveMachine(ctx, m); err != nil {
+		correlation.Logf(ctx, "[transition] save %s failed: %v", id, err)
+		return
 	}
+	s.cacheMachine(m)
 
-	s.publishEvent(ctx, map[string]interface{}{
-		"event":  "machine.running",
-		"id":     m.ID,
-		"status": "running",
-		"time":   time.Now().Unix(),
-	})
+	s.publishEvent(ctx, m, "machine.running", fields)
+}
+
+// now is the server clock as seen by m's host, which may be skewed by chaos.
+func (s *Server) now(m *models.Machine) time.Time {
+	return s.clock.Now().Add(s.chaos.Skew(chaos.Target{Region: m.Region, MachineID: m.ID})).UTC()
 }
 
 func (s *Server) getMachineCached(ctx context.Context, id string) (*models.Machine, error) {
@@ -216,6 +570,27 @@ func (s *Server) getMachineCached(ctx context.Context, id string) (*models.Machi
 	return m, nil
 }
 
+// cacheMachine caches m as the latest state of its machine and wakes
+// WaitMachine callers.
+func (s *Server) cacheMachine(m *models.Machine) {
+	s.mu.Lock()
+	s.cache[m.ID] = m
+	s.wakeLocked()
+	s.mu.Unlock()
+}
+
+// wake makes WaitMachine callers re-read the machines they wait on.
+func (s *Server) wake() {
+	s.mu.Lock()
+	s.wakeLocked()
+	s.mu.Unlock()
+}
+
+func (s *Server) wakeLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
 func (s *Server) acquireOpLock(id string) *sync.Mutex {
 	v, _ := s.opMu.LoadOrStore(id, &sync.Mutex{})
 	mtx := v.(*sync.Mutex)
@@ -232,13 +607,36 @@ func (s *Server) releaseOpLock(id string) {
 	mtx.Unlock()
 }
 
-func (s *Server) publishEvent(ctx context.Context, ev map[string]interface{}) {
-	if s.publisher == nil {
+// publishEvent publishes a machine event on its hierarchical subject. The
+// event name, machine ID, region, timestamp and correlation ID (when ctx has
+// one) are always included; fields carries the event-specific extras. Events
+// from a partitioned host are lost; machine.reconciled covers them on heal.
+func (s *Server) publishEvent(ctx context.Context, m *models.Machine, event string, fields map[string]interface{}) {
+	if s.sink == nil {
 		return
 	}
+	if s.unreachable(m) {
+		correlation.Logf(ctx, "[events] %s for %s lost: region %s partitioned", event, m.ID, m.Region)
+		return
+	}
+	ev := map[string]interface{}{
+		"event":  event,
+		"id":     m.ID,
+		"region": m.Region,
+		"time":   s.now(m).Unix(),
+	}
+	if rid := correlation.FromContext(ctx); rid != "" {
+		ev["correlation_id"] = rid
+	}
+	for k, v := range fields {
+		ev[k] = v
+	}
 	data, err := json.Marshal(ev)
 	if err != nil {
 		return
 	}
-	_ = s.publisher.Publish(ctx, "machines.events", data)
+	subject := natsclient.MachineSubject(m.Region, m.ID, strings.TrimPrefix(event, "machine."))
+	if err := s.sink.Publish(ctx, subject, data); err != nil {
+		correlation.Logf(ctx, "[events] publish %s failed: %v", subject, err)
+	}
 }
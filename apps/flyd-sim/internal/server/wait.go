@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+	proto "github.com/devghori1264/aerophoenix/flyd-sim/internal/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultWaitTimeout is how long WaitMachine waits when the request sets
+	// no timeout.
+	DefaultWaitTimeout = 30 * time.Second
+	// MaxWaitTimeout caps how long one WaitMachine call may block.
+	MaxWaitTimeout = 5 * time.Minute
+)
+
+// WaitMachine outcomes.
+const (
+	WaitReached = "reached"
+	WaitFailed  = "failed"
+	WaitTimeout = "timeout"
+)
+
+// waitable are the statuses a caller may wait for.
+var waitable = map[string]bool{
+	models.StatusPending:     true,
+	models.StatusStarting:    true,
+	models.StatusRunning:     true,
+	models.StatusStopped:     true,
+	models.StatusCrashed:     true,
+	models.StatusExited:      true,
+	models.StatusTerminated:  true,
+	models.StatusUnreachable: true,
+	models.StatusLost:        true,
+	models.StatusSuspended:   true,
+}
+
+// WaitMachine blocks until the machine reports req.Status, fails with no
+// restart to come or the timeout passes on the server clock, and returns the
+// machine's snapshot at that point along with the outcome.
+func (s *Server) WaitMachine(ctx context.Context, req *proto.WaitRequest) (*proto.WaitResponse, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id required")
+	}
+	if !waitable[req.Status] {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown status %q", req.Status)
+	}
+	if req.TimeoutMs < 0 {
+		return nil, status.Error(codes.InvalidArgument, "timeout_ms must be non-negative")
+	}
+	timeout := time.Duration(req.TimeoutMs) * time.Millisecond
+	if timeout == 0 {
+		timeout = DefaultWaitTimeout
+	}
+	if timeout > MaxWaitTimeout {
+		timeout = MaxWaitTimeout
+	}
+
+	expired := make(chan struct{})
+	timer := s.clock.AfterFunc(timeout, func() { close(expired) })
+	defer timer.Stop()
+
+	for {
+		// Take the channel before reading the machine so a change in
+		// between still wakes this loop.
+		s.mu.RLock()
+		changed := s.changed
+		s.mu.RUnlock()
+
+		m, err := s.getMachineCached(ctx, req.Id)
+		if err != nil {
+			return nil, err
+		}
+		snap := s.snapshot(m)
+		switch {
+		case snap.Status == req.Status:
+			return &proto.WaitResponse{Machine: snap, Outcome: WaitReached}, nil
+		case failedForGood(snap):
+			return &proto.WaitResponse{Machine: snap, Outcome: WaitFailed}, nil
+		}
+
+		select {
+		case <-changed:
+		case <-expired:
+			return &proto.WaitResponse{Machine: snap, Outcome: WaitTimeout}, nil
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// failedForGood reports whether the machine in snap is down and will not come
+// back on its own: it was destroyed, or it crashed or lost its host and its
+// restart policy allows no further restart. It judges the snapshot the caller
+// gets, so a machine reported unreachable is still waited on.
+func failedForGood(snap *proto.GetResponse) bool {
+	policy := models.RestartPolicy{
+		Policy:     snap.RestartPolicy.GetPolicy(),
+		MaxRetries: int(snap.RestartPolicy.GetMaxRetries()),
+	}
+	switch snap.Status {
+	case models.StatusTerminated:
+		return true
+	case models.StatusCrashed, models.StatusExited:
+		return !policy.ShouldRestart(int(snap.ExitCode), int(snap.RestartCount))
+	case models.StatusLost:
+		return !policy.ShouldRestart(hostLostExitCode, int(snap.RestartCount))
+	}
+	return false
+}
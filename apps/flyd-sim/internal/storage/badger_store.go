@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"path/filepath"
+	"time"
 
 	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
 	badger "github.com/dgraph-io/badger/v4"
@@ -18,6 +19,12 @@ var (
 type Store interface {
 	SaveMachine(ctx context.Context, m *models.Machine) error
 	GetMachine(ctx context.Context, id string) (*models.Machine, error)
+	ListMachines(ctx context.Context) ([]*models.Machine, error)
+	DeleteMachine(ctx context.Context, id string) error
+	// SaveLease stores a machine's lease; it is dropped after ttl.
+	SaveLease(ctx context.Context, l *models.Lease, ttl time.Duration) error
+	GetLease(ctx context.Context, machineID string) (*models.Lease, error)
+	DeleteLease(ctx context.Context, machineID string) error
 	Close() error
 }
 
@@ -41,8 +48,10 @@ func (s *BadgerStore) Close() error {
 	return s.db.Close()
 }
 
+const machinePrefix = "machine:"
+
 func machineKey(id string) []byte {
-	return []byte("machine:" + id)
+	return []byte(machinePrefix + id)
 }
 
 func (s *BadgerStore) SaveMachine(ctx context.Context, m *models.Machine) error {
@@ -74,3 +83,80 @@ func (s *BadgerStore) GetMachine(ctx context.Context, id string) (*models.Machin
 	}
 	return &out, nil
 }
+
+func (s *BadgerStore) ListMachines(ctx context.Context) ([]*models.Machine, error) {
+	var out []*models.Machine
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(machinePrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var m models.Machine
+			if err := it.Item().Value(func(v []byte) error {
+				return json.Unmarshal(v, &m)
+			}); err != nil {
+				return err
+			}
+			out = append(out, &m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *BadgerStore) DeleteMachine(ctx context.Context, id string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get(machineKey(id)); err != nil {
+			if err == badger.ErrKeyNotFound {
+				return ErrNotFound
+			}
+			return err
+		}
+		return txn.Delete(machineKey(id))
+	})
+}
+
+const leasePrefix = "lease:"
+
+func leaseKey(machineID string) []byte {
+	return []byte(leasePrefix + machineID)
+}
+
+func (s *BadgerStore) SaveLease(ctx context.Context, l *models.Lease, ttl time.Duration) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		data, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		return txn.SetEntry(badger.NewEntry(leaseKey(l.MachineID), data).WithTTL(ttl))
+	})
+}
+
+func (s *BadgerStore) GetLease(ctx context.Context, machineID string) (*models.Lease, error) {
+	var out models.Lease
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(leaseKey(machineID))
+		if err != nil {
+			if err == badger.ErrKeyNotFound {
+				return ErrNotFound
+			}
+			return err
+		}
+		return item.Value(func(v []byte) error {
+			return json.Unmarshal(v, &out)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *BadgerStore) DeleteLease(ctx context.Context, machineID string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(leaseKey(machineID))
+	})
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+)
+
+// ChaosStore wraps a Store and lets the chaos engine's storage faults fail,
+// slow down or corrupt its operations. A failed write never reaches the
+// wrapped store. To serve stale and torn reads it remembers the last two
+// records written for each machine.
+type ChaosStore struct {
+	Store
+	chaos *chaos.Engine
+
+	mu   sync.Mutex
+	last map[string]*models.Machine
+	prev map[string]*models.Machine
+}
+
+func NewChaosStore(inner Store, e *chaos.Engine) *ChaosStore {
+	return &ChaosStore{
+		Store: inner,
+		chaos: e,
+		last:  make(map[string]*models.Machine),
+		prev:  make(map[string]*models.Machine),
+	}
+}
+
+func (s *ChaosStore) SaveMachine(ctx context.Context, m *models.Machine) error {
+	t := chaos.Target{Region: m.Region, MachineID: m.ID, Method: "SaveMachine"}
+	if _, err := s.chaos.Storage(ctx, t, true); err != nil {
+		return err
+	}
+	if err := s.Store.SaveMachine(ctx, m); err != nil {
+		return err
+	}
+	cp := *m
+	s.mu.Lock()
+	s.prev[m.ID] = s.last[m.ID]
+	s.last[m.ID] = &cp
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *ChaosStore) GetMachine(ctx context.Context, id string) (*models.Machine, error) {
+	m, err := s.Store.GetMachine(ctx, id)
+	t := chaos.Target{MachineID: id, Method: "GetMachine"}
+	if m != nil {
+		t.Region = m.Region
+	}
+	mode, ferr := s.chaos.Storage(ctx, t, false)
+	if ferr != nil {
+		return nil, ferr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m = s.corrupt(m, mode); m == nil {
+		return nil, ErrNotFound
+	}
+	return m, nil
+}
+
+func (s *ChaosStore) ListMachines(ctx context.Context) ([]*models.Machine, error) {
+	machines, err := s.Store.ListMachines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := machines[:0]
+	for _, m := range machines {
+		mode, err := s.chaos.Storage(ctx, chaos.Target{Region: m.Region, MachineID: m.ID, Method: "ListMachines"}, false)
+		if err != nil {
+			return nil, err
+		}
+		if m = s.corrupt(m, mode); m != nil {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (s *ChaosStore) DeleteMachine(ctx context.Context, id string) error {
+	t := chaos.Target{MachineID: id, Method: "DeleteMachine"}
+	s.mu.Lock()
+	if m := s.last[id]; m != nil {
+		t.Region = m.Region
+	}
+	s.mu.Unlock()
+	if _, err := s.chaos.Storage(ctx, t, true); err != nil {
+		return err
+	}
+	if err := s.Store.DeleteMachine(ctx, id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.last, id)
+	delete(s.prev, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// corrupt applies a torn or stale read to m. A stale read of a machine with
+// no earlier write returns nil, as the machine did not exist yet.
+func (s *ChaosStore) corrupt(m *models.Machine, mode string) *models.Machine {
+	if mode == "" {
+		return m
+	}
+	s.mu.Lock()
+	prev := s.prev[m.ID]
+	s.mu.Unlock()
+	switch mode {
+	case chaos.ModeStale:
+		if prev == nil {
+			return nil
+		}
+		cp := *prev
+		return &cp
+	case chaos.ModeTorn:
+		// Status and exit state from the older write, everything else
+		// from the newer one.
+		if prev != nil {
+			torn := *m
+			torn.Status = prev.Status
+			torn.ExitCode = prev.ExitCode
+			return &torn
+		}
+	}
+	return m
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// KVMirror wraps a Store and mirrors the latest snapshot of every machine into
+// a NATS KV bucket keyed by machine ID. The wrapped store stays the source of
+// truth: the bucket is only written after a successful save, and mirror
+// failures are logged rather than returned.
+//
+// The bucket shows what callers of the server would see. A destroyed machine
+// is deleted, leaving a tombstone. A machine behind a chaos partition is
+// mirrored as unreachable, and the saves made while it is cut off are only
+// mirrored once the partition heals.
+type KVMirror struct {
+	Store
+	kv    jetstream.KeyValue
+	chaos *chaos.Engine
+}
+
+func NewKVMirror(inner Store, kv jetstream.KeyValue, e *chaos.Engine) *KVMirror {
+	s := &KVMirror{Store: inner, kv: kv, chaos: e}
+	e.AddNotifier(s.onChaosEvent)
+	return s
+}
+
+// Sync writes every stored machine into the bucket so watchers start from a
+// complete view after flyd-sim restarts.
+func (s *KVMirror) Sync(ctx context.Context) error {
+	machines, err := s.Store.ListMachines(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range machines {
+		s.mirror(ctx, m)
+	}
+	return nil
+}
+
+func (s *KVMirror) SaveMachine(ctx context.Context, m *models.Machine) error {
+	if err := s.Store.SaveMachine(ctx, m); err != nil {
+		return err
+	}
+	s.mirror(ctx, m)
+	return nil
+}
+
+// DeleteMachine removes the machine and leaves a delete marker in the bucket,
+// so KV watchers see the removal instead of the key silently going stale.
+func (s *KVMirror) DeleteMachine(ctx context.Context, id string) error {
+	if err := s.Store.DeleteMachine(ctx, id); err != nil {
+		return err
+	}
+	s.tombstone(ctx, id)
+	return nil
+}
+
+func target(m *models.Machine) chaos.Target {
+	return chaos.Target{Region: m.Region, MachineID: m.ID}
+}
+
+// mirror writes m unless a partition hides it, in which case the bucket keeps
+// showing it as unreachable.
+func (s *KVMirror) mirror(ctx context.Context, m *models.Machine) {
+	switch {
+	case s.chaos.Partitioned(target(m)):
+	case m.Status == models.StatusTerminated:
+		s.tombstone(ctx, m.ID)
+	default:
+		s.put(ctx, m)
+	}
+}
+
+// onChaosEvent marks the machines a partition cuts off as unreachable, and
+// mirrors the true state of the machines it covered once it heals.
+func (s *KVMirror) onChaosEvent(ev chaos.Event) {
+	if ev.Fault.Kind != chaos.KindPartition || (ev.Type != chaos.EventApplied && ev.Type != chaos.EventHealed) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	machines, err := s.Store.ListMachines(ctx)
+	if err != nil {
+		log.Printf("[kv] list machines failed: %v", err)
+		return
+	}
+	for _, m := range machines {
+		// Destroyed machines were tombstoned when they were destroyed.
+		if m.Status == models.StatusTerminated || !ev.Fault.Scope.Matches(target(m)) {
+			continue
+		}
+		if ev.Type == chaos.EventHealed {
+			// Another partition may still cover m.
+			s.mirror(ctx, m)
+			continue
+		}
+		// Withhold the same fields GetMachine does.
+		cp := *m
+		cp.Status = models.StatusUnreachable
+		cp.ExitCode = 0
+		cp.RestartCount = 0
+		s.put(ctx, &cp)
+	}
+}
+
+func (s *KVMirror) tombstone(ctx context.Context, id string) {
+	if err := s.kv.Delete(ctx, id); err != nil {
+		log.Printf("[kv] tombstone %s failed: %v", id, err)
+	}
+}
+
+func (s *KVMirror) put(ctx context.Context, m *models.Machine) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	if _, err := s.kv.Put(ctx, m.ID, data); err != nil {
+		log.Printf("[kv] mirror %s failed: %v", m.ID, err)
+	}
+}
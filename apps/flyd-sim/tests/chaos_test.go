@@ -0,0 +1,316 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	proto "github.com/devghori1264/aerophoenix/flyd-sim/internal/proto"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/storage"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPartitionHidesMachinesUntilHealReconciles(t *testing.T) {
+	sink := &recordingSink{}
+	env := newTestServer(t, withSink(sink))
+	s, engine, h := env.srv, env.engine, env.handler()
+	ctx := context.Background()
+
+	// Calling the server directly skips the entry-point checks, so the
+	// machine is created inside the partition and boots there unseen.
+	engine.Partition("iad", 0)
+	createRes, err := s.CreateMachine(ctx, &proto.CreateRequest{Name: "web", Region: "iad"})
+	if err != nil {
+		t.Fatalf("create err: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/create", strings.NewReader(`{"name":"api","region":"iad"}`)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for partitioned region over HTTP, got %d", rec.Code)
+	}
+
+	time.Sleep(700 * time.Millisecond)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/get?id="+createRes.Id, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"unreachable"`) {
+		t.Fatalf("expected machine in partitioned region to be unreachable, got %d %s", rec.Code, rec.Body.String())
+	}
+	if n := sink.count(".running"); n != 0 {
+		t.Fatalf("expected no events out of a partition, got %d running events", n)
+	}
+
+	engine.Heal("iad")
+	if g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: createRes.Id}); g.Status != "running" {
+		t.Fatalf("expected boot to have completed inside the partition, got %s", g.Status)
+	}
+	if n := sink.count(createRes.Id + ".reconciled"); n != 1 {
+		t.Fatalf("expected one reconciliation event on heal, got %d", n)
+	}
+}
+
+func TestErrorFaultIsReproducibleWithSeed(t *testing.T) {
+	run := func() []bool {
+		engine := chaos.NewEngine(chaos.WithSeed(42))
+		if _, err := engine.Add(chaos.Fault{
+			Kind:        chaos.KindError,
+			Scope:       chaos.Scope{Method: "StartMachine"},
+			Probability: 0.5,
+			Code:        codes.ResourceExhausted,
+		}); err != nil {
+			t.Fatalf("add fault: %v", err)
+		}
+		var hits []bool
+		for i := 0; i < 32; i++ {
+			_, err := engine.Apply(context.Background(), chaos.Target{Region: "ams", Method: "StartMachine"})
+			if err != nil && status.Code(err) != codes.ResourceExhausted {
+				t.Fatalf("unexpected code %v", status.Code(err))
+			}
+			hits = append(hits, err != nil)
+		}
+		if _, err := engine.Apply(context.Background(), chaos.Target{Region: "ams", Method: "StopMachine"}); err != nil {
+			t.Fatalf("fault scoped to StartMachine hit StopMachine: %v", err)
+		}
+		return hits
+	}
+
+	first, second := run(), run()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("runs with the same seed diverged at call %d", i)
+		}
+	}
+}
+
+func TestTimedFaultHealsItself(t *testing.T) {
+	evs := make(chan chaos.Event, 4)
+	engine := chaos.NewEngine(chaos.WithNotifier(func(ev chaos.Event) { evs <- ev }))
+
+	f := engine.Partition("ams", 100*time.Millisecond)
+	if f.ExpiresAt == nil {
+		t.Fatalf("expected timed fault to carry an expiry")
+	}
+	if st := engine.State(); len(st.Faults) != 1 || st.Faults[0].RemainingMs <= 0 {
+		t.Fatalf("expected one fault with time remaining, got %+v", st.Faults)
+	}
+
+	for _, want := range []string{chaos.EventApplied, chaos.EventHealed} {
+		select {
+		case ev := <-evs:
+			if ev.Type != want || ev.Fault.ID != f.ID {
+				t.Fatalf("expected %s for %s, got %s for %s", want, f.ID, ev.Type, ev.Fault.ID)
+			}
+			if want == chaos.EventHealed && ev.Reason != chaos.ReasonExpired {
+				t.Fatalf("expected expired reason, got %q", ev.Reason)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+	if engine.IsPartitioned("ams") {
+		t.Fatalf("partition still active after expiry")
+	}
+}
+
+func TestScenarioAppliesStepsOnTimeline(t *testing.T) {
+	sc, err := chaos.ParseScenario([]byte(`
+name: quick-outage
+steps:
+  - at: 100ms
+    fault: {kind: latency, scope: {region: ams}, latency_ms: 300}
+    duration: 100ms
+  - at: 0s
+    choose:
+      - fault: {kind: partition, scope: {region: iad}}
+        duration: 150ms
+      - fault: {kind: error, probability: 0.5, code: UNAVAILABLE, scope: {region: iad}}
+        duration: 150ms
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	var steps []chaos.Event
+	done := make(chan struct{})
+	engine := chaos.NewEngine(chaos.WithNotifier(func(ev chaos.Event) {
+		switch ev.Type {
+		case chaos.EventScenarioStep:
+			steps = append(steps, ev)
+		case chaos.EventScenarioFinished:
+			close(done)
+		}
+	}))
+	runner := chaos.NewScenarioRunner(engine)
+	run := runner.Start(sc)
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("scenario did not finish")
+	}
+	if len(steps) != 2 || steps[0].Fault.Scope.Region != "iad" || steps[1].Fault.Scope.Region != "ams" {
+		t.Fatalf("expected iad step then ams step, got %+v", steps)
+	}
+	if steps[0].Fault.Source != run.ID {
+		t.Fatalf("expected fault source %s, got %s", run.ID, steps[0].Fault.Source)
+	}
+	if got, _ := runner.Get(run.ID); got.State != chaos.RunFinished {
+		t.Fatalf("expected finished, got %s", got.State)
+	}
+	if st := engine.State(); len(st.Faults) != 0 {
+		t.Fatalf("expected all scenario faults healed, got %+v", st.Faults)
+	}
+}
+
+func TestInfiniteScenarioNeedsLoopLength(t *testing.T) {
+	if _, err := chaos.ParseScenario([]byte(`
+name: spin
+loops: -1
+steps:
+  - at: 0s
+    fault: {kind: partition, scope: {region: iad}}
+`)); err == nil {
+		t.Fatalf("expected a zero-length infinite scenario to be rejected")
+	}
+	if _, err := chaos.ParseScenario([]byte(`
+name: flap
+loops: -1
+steps:
+  - at: 0s
+    fault: {kind: partition, scope: {region: iad}}
+    duration: 1s
+`)); err != nil {
+		t.Fatalf("expected an infinite scenario with a positive loop length, got %v", err)
+	}
+}
+
+func TestCrashFaultHonoursRestartPolicy(t *testing.T) {
+	env := newTestServer(t)
+	s, engine := env.srv, env.engine
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	res, err := s.CreateMachine(ctx, &proto.CreateRequest{
+		Name:          "worker",
+		Region:        "ord",
+		RestartPolicy: &proto.RestartPolicy{Policy: "on-failure", MaxRetries: 1},
+	})
+	if err != nil {
+		t.Fatalf("create err: %v", err)
+	}
+	waitForStatus(t, s, res.Id, "running")
+
+	if _, err := engine.Add(chaos.Fault{
+		Kind:      chaos.KindCrash,
+		Scope:     chaos.Scope{MachineID: res.Id},
+		MTBFMs:    1,
+		ExitCodes: []int{137},
+	}); err != nil {
+		t.Fatalf("add fault: %v", err)
+	}
+	go s.RunCrashInjector(ctx, 50*time.Millisecond)
+
+	// One restart is allowed; the second crash leaves the machine down.
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: res.Id})
+		if g.Status == "crashed" && g.RestartCount == 1 {
+			if g.ExitCode != 137 {
+				t.Fatalf("expected exit code 137, got %d", g.ExitCode)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected crashed after one restart, got %s with %d restarts", g.Status, g.RestartCount)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestInvalidRestartPolicyIsInvalidArgument(t *testing.T) {
+	env := newTestServer(t)
+	s, h := env.srv, env.handler()
+	for _, rp := range []*proto.RestartPolicy{
+		{Policy: "sometimes"},
+		{Policy: "on-failure", MaxRetries: -1},
+	} {
+		_, err := s.CreateMachine(context.Background(), &proto.CreateRequest{Name: "worker", Region: "ord", RestartPolicy: rp})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument for %+v, got %v", rp, err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/create", strings.NewReader(`{"name":"worker","region":"ord","restart_policy":{"policy":"sometimes"}}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 over HTTP, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+type recordingSink struct {
+	mu       sync.Mutex
+	subjects []string
+}
+
+func (r *recordingSink) Publish(_ context.Context, subject string, _ []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subjects = append(r.subjects, subject)
+	return nil
+}
+
+func (r *recordingSink) Close() error { return nil }
+
+func (r *recordingSink) count(suffix string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, s := range r.subjects {
+		if strings.HasSuffix(s, suffix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestFailedSaveDoesNotPublish(t *testing.T) {
+	sink := &recordingSink{}
+	env := newTestServer(t, withSink(sink), withStore(func(store storage.Store, engine *chaos.Engine) storage.Store {
+		return storage.NewChaosStore(store, engine)
+	}))
+	s, engine := env.srv, env.engine
+	ctx := context.Background()
+
+	res, err := s.CreateMachine(ctx, &proto.CreateRequest{Name: "db", Region: "fra"})
+	if err != nil {
+		t.Fatalf("create err: %v", err)
+	}
+	waitForStatus(t, s, res.Id, "running")
+
+	if _, err := engine.Add(chaos.Fault{
+		Kind:        chaos.KindStorage,
+		Mode:        chaos.ModeError,
+		Op:          chaos.OpWrite,
+		Scope:       chaos.Scope{MachineID: res.Id},
+		Probability: 1,
+	}); err != nil {
+		t.Fatalf("add fault: %v", err)
+	}
+
+	if _, err := s.StopMachine(ctx, &proto.ActionRequest{Id: res.Id}); status.Code(err) != codes.Internal {
+		t.Fatalf("expected injected Internal error, got %v", err)
+	}
+	if g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: res.Id}); g.Status != "running" {
+		t.Fatalf("expected unsaved stop to leave machine running, got %s", g.Status)
+	}
+	if n := sink.count(".stop"); n != 0 {
+		t.Fatalf("expected no stop event for a failed save, got %d", n)
+	}
+}
@@ -0,0 +1,433 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/clock"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/correlation"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/events"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/heartbeat"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+	natsclient "github.com/devghori1264/aerophoenix/flyd-sim/internal/nats"
+	proto "github.com/devghori1264/aerophoenix/flyd-sim/internal/proto"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestWebhookSinkSignsAndRetries(t *testing.T) {
+	const secret = "s3cret"
+	var attempts atomic.Int32
+	delivered := make(chan struct{})
+
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(events.TimestampHeader), 10, 64)
+		if got, want := r.Header.Get(events.SignatureHeader), "sha256="+events.Sign(secret, ts, r.Header.Get(events.SubjectHeader), body); got != want {
+			t.Errorf("signature mismatch: got %q want %q", got, want)
+		}
+		if got := r.Header.Get(events.SubjectHeader); got != "machines.events.iad.m1.created" {
+			t.Errorf("unexpected subject header %q", got)
+		}
+		close(delivered)
+	}))
+	defer hook.Close()
+
+	sink := events.NewWebhookSink(hook.URL, secret, events.WithBackoff(time.Millisecond, 5*time.Millisecond))
+	if err := sink.Publish(context.Background(), "machines.events.iad.m1.created", []byte(`{"event":"machine.created"}`)); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case <-delivered:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("webhook not delivered after %d attempts", attempts.Load())
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := sink.Publish(context.Background(), "x", nil); err != events.ErrSinkClosed {
+		t.Fatalf("expected ErrSinkClosed after close, got %v", err)
+	}
+}
+
+func TestWebhookSinkCloseIsBounded(t *testing.T) {
+	release := make(chan struct{})
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(events.SubjectHeader) == "hang" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer hook.Close()
+	defer close(release)
+
+	// A delivery waiting out its backoff is dropped as soon as Close is called.
+	sink := events.NewWebhookSink(hook.URL, "", events.WithBackoff(time.Minute, time.Minute))
+	if err := sink.Publish(context.Background(), "retry", nil); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("close waited out the retry backoff: %s", d)
+	}
+
+	// A receiver that never answers holds Close for the drain timeout only.
+	sink = events.NewWebhookSink(hook.URL, "", events.WithDrainTimeout(100*time.Millisecond))
+	for i := 0; i < 3; i++ {
+		if err := sink.Publish(context.Background(), "hang", nil); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+	start = time.Now()
+	if err := sink.Close(); err != events.ErrDrainTimeout {
+		t.Fatalf("expected ErrDrainTimeout, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("close was not bounded by the drain timeout: %s", d)
+	}
+}
+
+func TestMachineSubject(t *testing.T) {
+	for _, tc := range []struct {
+		region, id, eventType string
+		want                  string
+	}{
+		{"iad", "m1", "created", "machines.events.iad.m1.created"},
+		{"", "m1", "stop", "machines.events._.m1.stop"},
+		// Wildcards and separators cannot widen the subject.
+		{"iad.ord", "m*", "start>", "machines.events.iad_ord.m_.start_"},
+		{"a b", "m\t1", "", "machines.events.a_b.m_1._"},
+	} {
+		if got := natsclient.MachineSubject(tc.region, tc.id, tc.eventType); got != tc.want {
+			t.Fatalf("MachineSubject(%q, %q, %q) = %s, want %s", tc.region, tc.id, tc.eventType, got, tc.want)
+		}
+	}
+}
+
+func TestParseMachineSubject(t *testing.T) {
+	for _, tc := range []struct {
+		subject               string
+		region, id, eventType string
+		ok                    bool
+	}{
+		{natsclient.MachineSubject("iad", "m1", "created"), "iad", "m1", "created", true},
+		{natsclient.MachineSubject("iad.ord", "", "stop"), "iad_ord", "_", "stop", true},
+		{"machines.events.iad.m1.created.extra", "", "", "", false},
+		{"machines.events.iad.m1", "", "", "", false},
+		{natsclient.EventsSubject, "", "", "", false},
+		{natsclient.ChaosEventSubject("iad", "applied"), "", "", "", false},
+		{"flyd.heartbeat", "", "", "", false},
+	} {
+		region, id, eventType, ok := natsclient.ParseMachineSubject(tc.subject)
+		if ok != tc.ok || region != tc.region || id != tc.id || eventType != tc.eventType {
+			t.Fatalf("ParseMachineSubject(%q) = %q, %q, %q, %v, want %q, %q, %q, %v",
+				tc.subject, region, id, eventType, ok, tc.region, tc.id, tc.eventType, tc.ok)
+		}
+	}
+}
+
+func TestHeartbeatSummarisesFleet(t *testing.T) {
+	env := newTestServer(t)
+	ctx := context.Background()
+	for _, m := range []*models.Machine{
+		{ID: "a", Region: "iad", Status: "running"},
+		{ID: "b", Region: "iad", Status: "stopped"},
+		{ID: "c", Region: "ams", Status: "running"},
+		// Destroyed machines are not part of the fleet.
+		{ID: "d", Region: "sin", Status: models.StatusTerminated},
+	} {
+		if err := env.store.SaveMachine(ctx, m); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	// The heartbeat reports c the way GetMachine does while it is cut off.
+	if _, err := env.engine.Add(chaos.Fault{Kind: chaos.KindPartition, Scope: chaos.Scope{Region: "ams", MachineID: "c"}}); err != nil {
+		t.Fatalf("add fault: %v", err)
+	}
+
+	e := heartbeat.NewEmitter(heartbeat.Config{HostID: "h1", Version: "test"}, env.store, nil, env.engine)
+	hb, err := e.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if hb.HostID != "h1" || hb.Version != "test" || hb.Machines != 3 || hb.Chaos == nil || len(hb.Chaos.Faults) != 1 {
+		t.Fatalf("unexpected heartbeat %+v", hb)
+	}
+	if hb.ByRegion["iad"] != 2 || hb.ByRegion["ams"] != 1 || len(hb.ByRegion) != 2 {
+		t.Fatalf("unexpected by_region %v", hb.ByRegion)
+	}
+	if hb.ByStatus["running"] != 1 || hb.ByStatus["stopped"] != 1 || hb.ByStatus[models.StatusUnreachable] != 1 || len(hb.ByStatus) != 3 {
+		t.Fatalf("unexpected by_status %v", hb.ByStatus)
+	}
+}
+
+// createdSink keeps the NATS message each machine.created event would be
+// sent as, by machine ID.
+type createdSink struct {
+	mu   sync.Mutex
+	msgs map[string]*nats.Msg
+}
+
+func (c *createdSink) Publish(ctx context.Context, subject string, data []byte) error {
+	var ev struct {
+		Event string `json:"event"`
+		ID    string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &ev); err != nil || ev.Event != "machine.created" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.msgs[ev.ID] = natsclient.NewMsg(ctx, subject, data)
+	return nil
+}
+
+func (c *createdSink) Close() error { return nil }
+
+// requestID returns the correlation ID the created event of id carried in
+// its NATS header, after checking its payload carries the same one.
+func (c *createdSink) requestID(t *testing.T, id string) string {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	msg := c.msgs[id]
+	if msg == nil {
+		t.Fatalf("no created event for %s", id)
+	}
+	var ev struct {
+		CorrelationID string `json:"correlation_id"`
+	}
+	if err := json.Unmarshal(msg.Data, &ev); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	if rid := msg.Header.Get(correlation.Header); rid != ev.CorrelationID {
+		t.Fatalf("NATS header %q and event payload %q disagree", rid, ev.CorrelationID)
+	}
+	return ev.CorrelationID
+}
+
+// headerStream records the response header a gRPC handler sets.
+type headerStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (h *headerStream) SetHeader(md metadata.MD) error {
+	h.header = metadata.Join(h.header, md)
+	return nil
+}
+
+func TestCorrelationIDReachesEventsAndResponses(t *testing.T) {
+	sink := &createdSink{msgs: map[string]*nats.Msg{}}
+	env := newTestServer(t, withSink(sink))
+	s, h := env.srv, env.handler()
+
+	createHTTP := func(rid string) (string, string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/create", strings.NewReader(`{"name":"web","region":"ord"}`))
+		if rid != "" {
+			req.Header.Set(correlation.Header, rid)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		var res struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || res.ID == "" {
+			t.Fatalf("create over HTTP: %d %s", rec.Code, rec.Body.String())
+		}
+		return res.ID, rec.Header().Get(correlation.Header)
+	}
+	id, echoed := createHTTP("req-http")
+	if echoed != "req-http" {
+		t.Fatalf("expected the HTTP request ID echoed, got %q", echoed)
+	}
+	if got := sink.requestID(t, id); got != "req-http" {
+		t.Fatalf("expected the HTTP request ID on the event, got %q", got)
+	}
+	id, echoed = createHTTP("")
+	if echoed == "" {
+		t.Fatalf("expected a generated request ID on the HTTP response")
+	}
+	if got := sink.requestID(t, id); got != echoed {
+		t.Fatalf("expected the generated ID %q on the event, got %q", echoed, got)
+	}
+
+	intercept := correlation.UnaryServerInterceptor()
+	createGRPC := func(md metadata.MD) (string, string) {
+		t.Helper()
+		stream := &headerStream{}
+		ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+		if md != nil {
+			ctx = metadata.NewIncomingContext(ctx, md)
+		}
+		res, err := intercept(ctx, &proto.CreateRequest{Name: "web", Region: "ord"}, &grpc.UnaryServerInfo{FullMethod: "/flyd.Flyd/CreateMachine"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.CreateMachine(ctx, req.(*proto.CreateRequest))
+			})
+		if err != nil {
+			t.Fatalf("create over gRPC: %v", err)
+		}
+		var rid string
+		if v := stream.header.Get(correlation.MetadataKey); len(v) > 0 {
+			rid = v[0]
+		}
+		return res.(*proto.CreateResponse).Id, rid
+	}
+	for _, key := range []string{"x-request-id", "x-correlation-id"} {
+		id, echoed = createGRPC(metadata.Pairs(key, "req-grpc"))
+		if echoed != "req-grpc" {
+			t.Fatalf("expected the gRPC %s echoed, got %q", key, echoed)
+		}
+		if got := sink.requestID(t, id); got != "req-grpc" {
+			t.Fatalf("expected the gRPC %s on the event, got %q", key, got)
+		}
+	}
+	id, echoed = createGRPC(nil)
+	if echoed == "" {
+		t.Fatalf("expected a generated request ID in the gRPC response header")
+	}
+	if got := sink.requestID(t, id); got != echoed {
+		t.Fatalf("expected the generated ID %q on the event, got %q", echoed, got)
+	}
+}
+
+func TestDeliveryChaosDropsDuplicatesAndReorders(t *testing.T) {
+	engine := chaos.NewEngine()
+	for _, f := range []chaos.Fault{
+		{Kind: chaos.KindDelivery, Mode: chaos.ModeDrop, Scope: chaos.Scope{Method: "stop"}},
+		{Kind: chaos.KindDelivery, Mode: chaos.ModeDuplicate, Scope: chaos.Scope{Method: "start"}},
+		{Kind: chaos.KindDelivery, Mode: chaos.ModeReorder, Scope: chaos.Scope{Method: "created"}},
+	} {
+		f.Probability = 1
+		if _, err := engine.Add(f); err != nil {
+			t.Fatalf("add fault: %v", err)
+		}
+	}
+	rec := &recordingSink{}
+	sink := events.NewDeliveryChaosSink(rec, engine)
+	ctx := context.Background()
+	for _, typ := range []string{"created", "running", "stop", "start"} {
+		if err := sink.Publish(ctx, natsclient.MachineSubject("iad", "m1", typ), nil); err != nil {
+			t.Fatalf("publish %s: %v", typ, err)
+		}
+	}
+	if err := sink.Publish(ctx, "flyd.heartbeat", nil); err != nil {
+		t.Fatalf("publish heartbeat: %v", err)
+	}
+	sink.Close()
+
+	var got []string
+	for _, s := range rec.subjects {
+		got = append(got, s[strings.LastIndex(s, ".")+1:])
+	}
+	want := "running,created,start,start,heartbeat"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("expected deliveries %s, got %s", want, strings.Join(got, ","))
+	}
+}
+
+func TestDeliveryChaosRunsOnEngineClock(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	engine := chaos.NewEngine(chaos.WithClock(clk))
+	for _, f := range []chaos.Fault{
+		{Kind: chaos.KindDelivery, Mode: chaos.ModeDelay, LatencyMs: 5000, Scope: chaos.Scope{Method: "running"}},
+		{Kind: chaos.KindDelivery, Mode: chaos.ModeReorder, Scope: chaos.Scope{Method: "created"}},
+	} {
+		f.Probability = 1
+		if _, err := engine.Add(f); err != nil {
+			t.Fatalf("add fault: %v", err)
+		}
+	}
+	rec := &recordingSink{}
+	sink := events.NewDeliveryChaosSink(rec, engine)
+	ctx := context.Background()
+	for _, typ := range []string{"running", "created"} {
+		if err := sink.Publish(ctx, natsclient.MachineSubject("iad", "m1", typ), nil); err != nil {
+			t.Fatalf("publish %s: %v", typ, err)
+		}
+	}
+
+	expect := func(suffix string, want int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for rec.count(suffix) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d %s deliveries, got %d", want, suffix, rec.count(suffix))
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	// The delay and the reorder window are both waiting on the fake clock.
+	clk.BlockUntil(2)
+	expect(".created", 0)
+	clk.Advance(time.Second)
+	expect(".created", 1)
+	expect(".running", 0)
+	clk.Advance(4 * time.Second)
+	expect(".running", 1)
+	sink.Close()
+}
+
+func TestHeartbeatUsesInjectedClock(t *testing.T) {
+	env := newTestServer(t, withFakeClock())
+	clk := env.clock
+	start := clk.Now()
+	e := heartbeat.NewEmitter(heartbeat.Config{HostID: "h1", Interval: time.Second, Clock: clk}, env.store, nil, nil)
+	clk.Advance(90 * time.Second)
+	hb, err := e.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if !hb.StartedAt.Equal(start) || hb.UptimeSeconds != 90 || hb.Time != start.Add(90*time.Second).Unix() {
+		t.Fatalf("expected heartbeat timed on the fake clock, got %+v", hb)
+	}
+}
+
+func TestWriterSinkAndChaosNotifierUseInjectedClock(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	clk.Advance(time.Hour)
+	var buf bytes.Buffer
+	sink := events.NewWriterSink(&buf, events.WithClock(clk))
+	notify := events.ChaosNotifier(sink, clk)
+	notify(chaos.Event{Type: chaos.EventApplied, Fault: chaos.Fault{ID: "f1", Kind: chaos.KindPartition, Scope: chaos.Scope{Region: "iad"}}})
+
+	var rec struct {
+		Subject string    `json:"subject"`
+		Time    time.Time `json:"time"`
+		Data    struct {
+			Time int64 `json:"time"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("decode record %q: %v", buf.String(), err)
+	}
+	if !rec.Time.Equal(clk.Now()) || rec.Data.Time != clk.Now().Unix() {
+		t.Fatalf("expected record and event stamped %s, got %+v", clk.Now(), rec)
+	}
+}
@@ -2,26 +2,24 @@ package tests
 
 import (
 	"context"
-	"os"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
-	natsclient "github.com/devghori1264/aerophoenix/flyd-sim/internal/nats"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/api"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
 	proto "github.com/devghori1264/aerophoenix/flyd-sim/internal/proto"
 	"github.com/devghori1264/aerophoenix/flyd-sim/internal/server"
-	"github.com/devghori1264/aerophoenix/flyd-sim/internal/storage"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestCreateStartStopSequence(t *testing.T) {
-	path := "./testdata/badger"
-	os.RemoveAll(path)
-	store, err := storage.NewBadgerStore(path)
-	if err != nil {
-		t.Fatalf("open badger: %v", err)
-	}
-	defer store.Close()
-
-	s := server.New(store, (*natsclient.Publisher)(nil))
+	env := newTestServer(t, withFakeClock())
+	s, clk := env.srv, env.clock
 
 	ctx := context.Background()
 	createRes, err := s.CreateMachine(ctx, &proto.CreateRequest{Name: "web", Region: "eu"})
@@ -29,11 +27,10 @@ func TestCreateStartStopSequence(t *testing.T) {
 		t.Fatalf("create err: %v", err)
 	}
 	id := createRes.Id
-	time.Sleep(700 * time.Millisecond)
-	gRes, err := s.GetMachine(ctx, &proto.GetRequest{Id: id})
-	if err != nil {
-		t.Fatalf("get err: %v", err)
-	}
+	// Wait for the background boot to start sleeping, then let it finish.
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	gRes := waitForStatus(t, s, id, "running")
 	if gRes.Status != "running" {
 		t.Fatalf("expected running got %s", gRes.Status)
 	}
@@ -46,3 +43,301 @@ func TestCreateStartStopSequence(t *testing.T) {
 		t.Fatalf("expected stopped got %s", gr.Status)
 	}
 }
+
+// waitForStatus waits until machine id reaches want, returning the last
+// response. Background transitions finish shortly after the fake clock moves.
+func waitForStatus(t *testing.T, s *server.Server, id, want string) *proto.GetResponse {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	res, err := s.WaitMachine(ctx, &proto.WaitRequest{Id: id, Status: want, TimeoutMs: time.Hour.Milliseconds()})
+	if status.Code(err) == codes.DeadlineExceeded {
+		g, err := s.GetMachine(context.Background(), &proto.GetRequest{Id: id})
+		if err != nil {
+			t.Fatalf("get err: %v", err)
+		}
+		return g
+	}
+	if err != nil {
+		t.Fatalf("wait err: %v", err)
+	}
+	return res.Machine
+}
+
+func TestWaitMachineOutcomes(t *testing.T) {
+	env := newTestServer(t, withFakeClock())
+	s, engine, clk := env.srv, env.engine, env.clock
+	h := api.NewHTTPHandler(s, engine, chaos.NewScenarioRunner(engine))
+	ctx := context.Background()
+	res, err := s.CreateMachine(ctx, &proto.CreateRequest{Name: "web", Region: "eu"})
+	if err != nil {
+		t.Fatalf("create err: %v", err)
+	}
+	id := res.Id
+
+	wait := func(want string, timeout time.Duration) <-chan *proto.WaitResponse {
+		out := make(chan *proto.WaitResponse, 1)
+		go func() {
+			w, err := s.WaitMachine(ctx, &proto.WaitRequest{Id: id, Status: want, TimeoutMs: timeout.Milliseconds()})
+			if err != nil {
+				t.Errorf("wait %s err: %v", want, err)
+			}
+			out <- w
+		}()
+		return out
+	}
+
+	// Boot sleep plus the wait's deadline.
+	running := wait("running", 5*time.Second)
+	clk.BlockUntil(2)
+	clk.Advance(time.Second)
+	if w := <-running; w.Outcome != server.WaitReached || w.Machine.Status != "running" {
+		t.Fatalf("expected running reached, got %v", w)
+	}
+
+	stopped := wait("stopped", 3*time.Second)
+	clk.BlockUntil(1)
+	clk.Advance(3 * time.Second)
+	if w := <-stopped; w.Outcome != server.WaitTimeout || w.Machine.Status != "running" {
+		t.Fatalf("expected timeout while running, got %v", w)
+	}
+
+	if _, err := s.DestroyMachine(ctx, &proto.ActionRequest{Id: id}); err != nil {
+		t.Fatalf("destroy err: %v", err)
+	}
+	if w := <-wait("running", time.Second); w.Outcome != server.WaitFailed || w.Machine.Status != "terminated" {
+		t.Fatalf("expected failed once destroyed, got %v", w)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/machines/"+id+"/wait?state=terminated&timeout=1s", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"outcome":"reached"`) {
+		t.Fatalf("expected reached over HTTP, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/machines/"+id+"/wait?state=asleep", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown state, got %d", rec.Code)
+	}
+}
+
+func TestWaitMachineSeesPartitions(t *testing.T) {
+	env := newTestServer(t, withFakeClock())
+	s, engine, clk := env.srv, env.engine, env.clock
+	h := env.handler()
+	ctx := context.Background()
+	res, err := s.CreateMachine(ctx, &proto.CreateRequest{Name: "web", Region: "iad"})
+	if err != nil {
+		t.Fatalf("create err: %v", err)
+	}
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	waitForStatus(t, s, res.Id, "running")
+
+	// The partition changes no machine state, but must still wake waiters.
+	unreachable := make(chan *proto.WaitResponse, 1)
+	go func() {
+		w, err := s.WaitMachine(ctx, &proto.WaitRequest{Id: res.Id, Status: "unreachable", TimeoutMs: 10_000})
+		if err != nil {
+			t.Errorf("wait err: %v", err)
+		}
+		unreachable <- w
+	}()
+	clk.BlockUntil(1)
+	engine.Partition("iad", 0)
+	select {
+	case w := <-unreachable:
+		if w == nil || w.Outcome != server.WaitReached {
+			t.Fatalf("expected unreachable reached, got %v", w)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("partition did not wake the waiter")
+	}
+
+	// Waiting goes through the partition like GetMachine, and the heal wakes it.
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/machines/"+res.Id+"/wait?state=running&timeout=10s", nil))
+	}()
+	clk.BlockUntil(1)
+	engine.Heal("iad")
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("heal did not wake the waiter")
+	}
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"outcome":"reached"`) {
+		t.Fatalf("expected running reached over HTTP, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// A machine destroyed behind a partition still reports unreachable, so
+	// waiting goes on until the timeout rather than failing on state the
+	// caller cannot see.
+	engine.Partition("iad", 0)
+	if _, err := s.DestroyMachine(ctx, &proto.ActionRequest{Id: res.Id}); err != nil {
+		t.Fatalf("destroy err: %v", err)
+	}
+	go func() {
+		clk.BlockUntil(1)
+		clk.Advance(10 * time.Second)
+	}()
+	w, err := s.WaitMachine(ctx, &proto.WaitRequest{Id: res.Id, Status: "running", TimeoutMs: 10_000})
+	if err != nil || w.Outcome != server.WaitTimeout || w.Machine.Status != "unreachable" {
+		t.Fatalf("expected an unreachable timeout while partitioned, got %v, %v", w, err)
+	}
+	engine.Heal("iad")
+	w, err = s.WaitMachine(ctx, &proto.WaitRequest{Id: res.Id, Status: "running", TimeoutMs: 10_000})
+	if err != nil || w.Outcome != server.WaitFailed || w.Machine.Status != "terminated" {
+		t.Fatalf("expected failed once the destroyed machine is visible, got %v, %v", w, err)
+	}
+}
+
+func TestClockSkewShiftsMachineTimestamps(t *testing.T) {
+	env := newTestServer(t, withFakeClock())
+	s, engine, clk, store := env.srv, env.engine, env.clock, env.store
+	if _, err := engine.Add(chaos.Fault{Kind: chaos.KindSkew, Scope: chaos.Scope{Region: "syd"}, SkewMs: -90_000}); err != nil {
+		t.Fatalf("add fault: %v", err)
+	}
+
+	ctx := context.Background()
+	for region, want := range map[string]time.Time{
+		"syd": clk.Now().Add(-90 * time.Second),
+		"ams": clk.Now(),
+	} {
+		res, err := s.CreateMachine(ctx, &proto.CreateRequest{Name: "clock", Region: region})
+		if err != nil {
+			t.Fatalf("create err: %v", err)
+		}
+		m, err := store.GetMachine(ctx, res.Id)
+		if err != nil {
+			t.Fatalf("load err: %v", err)
+		}
+		if !m.CreatedAt.Equal(want) {
+			t.Fatalf("expected %s machine created at %s, got %s", region, want, m.CreatedAt)
+		}
+	}
+}
+
+func TestLeaseGuardsMutationsUntilExpiry(t *testing.T) {
+	env := newTestServer(t, withFakeClock())
+	s, clk := env.srv, env.clock
+	ctx := context.Background()
+	res, err := s.CreateMachine(ctx, &proto.CreateRequest{Name: "web", Region: "eu"})
+	if err != nil {
+		t.Fatalf("create err: %v", err)
+	}
+	id := res.Id
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	waitForStatus(t, s, id, "running")
+
+	lease, err := s.AcquireLease(ctx, &proto.AcquireLeaseRequest{Id: id, TtlSeconds: 10, Owner: "orchestrator"})
+	if err != nil {
+		t.Fatalf("acquire err: %v", err)
+	}
+	if _, err := s.AcquireLease(ctx, &proto.AcquireLeaseRequest{Id: id}); status.Code(err) != codes.Aborted {
+		t.Fatalf("expected Aborted acquiring a held lease, got %v", err)
+	}
+	if _, err := s.StopMachine(ctx, &proto.ActionRequest{Id: id}); status.Code(err) != codes.Aborted {
+		t.Fatalf("expected Aborted stopping without the nonce, got %v", err)
+	}
+	if _, err := s.StopMachine(ctx, &proto.ActionRequest{Id: id, LeaseNonce: lease.Nonce}); err != nil {
+		t.Fatalf("stop with nonce err: %v", err)
+	}
+	if _, err := s.RenewLease(ctx, &proto.RenewLeaseRequest{Id: id, Nonce: "stale"}); status.Code(err) != codes.Aborted {
+		t.Fatalf("expected Aborted renewing with a wrong nonce, got %v", err)
+	}
+	clk.Advance(5 * time.Second)
+	renewed, err := s.RenewLease(ctx, &proto.RenewLeaseRequest{Id: id, Nonce: lease.Nonce, TtlSeconds: 10})
+	if err != nil || renewed.ExpiresAt != clk.Now().Add(10*time.Second).Unix() {
+		t.Fatalf("expected lease renewed for 10s from now, got %v, %v", renewed, err)
+	}
+
+	// The lease lapses without another renewal.
+	clk.Advance(11 * time.Second)
+	if _, err := s.GetLease(ctx, &proto.GetRequest{Id: id}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for an expired lease, got %v", err)
+	}
+	if _, err := s.StartMachine(ctx, &proto.ActionRequest{Id: id}); err != nil {
+		t.Fatalf("start after expiry err: %v", err)
+	}
+
+	lease, err = s.AcquireLease(ctx, &proto.AcquireLeaseRequest{Id: id})
+	if err != nil {
+		t.Fatalf("reacquire err: %v", err)
+	}
+	if _, err := s.ReleaseLease(ctx, &proto.ReleaseLeaseRequest{Id: id, Nonce: lease.Nonce}); err != nil {
+		t.Fatalf("release err: %v", err)
+	}
+	if _, err := s.MigrateMachine(ctx, &proto.MigrateRequest{Id: id, TargetRegion: "us"}); err != nil {
+		t.Fatalf("migrate after release err: %v", err)
+	}
+
+	if _, err := s.DestroyMachine(ctx, &proto.ActionRequest{Id: id}); err != nil {
+		t.Fatalf("destroy err: %v", err)
+	}
+	if _, err := s.AcquireLease(ctx, &proto.AcquireLeaseRequest{Id: id}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition leasing a destroyed machine, got %v", err)
+	}
+}
+
+func TestSuspendResumeAndRestart(t *testing.T) {
+	sink := &recordingSink{}
+	env := newTestServer(t, withFakeClock(), withSink(sink))
+	s, clk := env.srv, env.clock
+	ctx := context.Background()
+	res, err := s.CreateMachine(ctx, &proto.CreateRequest{Name: "web", Region: "eu"})
+	if err != nil {
+		t.Fatalf("create err: %v", err)
+	}
+	id := res.Id
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	waitForStatus(t, s, id, "running")
+
+	if _, err := s.ResumeMachine(ctx, &proto.ActionRequest{Id: id}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition resuming a running machine, got %v", err)
+	}
+	if _, err := s.SuspendMachine(ctx, &proto.ActionRequest{Id: id}); err != nil {
+		t.Fatalf("suspend err: %v", err)
+	}
+	g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: id})
+	if g.Status != "suspended" || g.Snapshot.GetMemoryMb() != 256 {
+		t.Fatalf("expected suspended with a 256 MB snapshot, got %s %v", g.Status, g.Snapshot)
+	}
+
+	// Resuming from the snapshot is quicker than booting.
+	if _, err := s.ResumeMachine(ctx, &proto.ActionRequest{Id: id}); err != nil {
+		t.Fatalf("resume err: %v", err)
+	}
+	clk.BlockUntil(1)
+	clk.Advance(100 * time.Millisecond)
+	if g := waitForStatus(t, s, id, "running"); g.Status != "running" || g.Snapshot != nil {
+		t.Fatalf("expected running without a snapshot after resume, got %s %v", g.Status, g.Snapshot)
+	}
+
+	if _, err := s.RestartMachine(ctx, &proto.ActionRequest{Id: id}); err != nil {
+		t.Fatalf("restart err: %v", err)
+	}
+	if _, err := s.RestartMachine(ctx, &proto.ActionRequest{Id: id}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition restarting a booting machine, got %v", err)
+	}
+	clk.BlockUntil(1)
+	clk.Advance(100 * time.Millisecond)
+	if g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: id}); g.Status != "starting" {
+		t.Fatalf("expected a restart to cold boot, got %s", g.Status)
+	}
+	clk.Advance(400 * time.Millisecond)
+	waitForStatus(t, s, id, "running")
+
+	for _, suffix := range []string{".suspend", ".resume", ".restart"} {
+		if n := sink.count(suffix); n != 1 {
+			t.Fatalf("expected one %s event, got %d", suffix, n)
+		}
+	}
+	if n := sink.count(".running"); n != 3 {
+		t.Fatalf("expected three running events, got %d", n)
+	}
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/api"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/clock"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/events"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/server"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/storage"
+)
+
+// testEnv is a server backed by a badger store in a per-test directory.
+type testEnv struct {
+	// store is the store the server was given, after any withStore wrapping.
+	store  storage.Store
+	engine *chaos.Engine
+	srv    *server.Server
+	// clock drives the server and engine when withFakeClock is set.
+	clock *clock.Fake
+}
+
+type testConfig struct {
+	fakeClock  bool
+	sink       events.EventSink
+	wrap       func(storage.Store, *chaos.Engine) storage.Store
+	serverOpts []server.Option
+}
+
+type testOption func(*testConfig)
+
+// withFakeClock runs the server and its chaos engine on a fake clock.
+func withFakeClock() testOption {
+	return func(c *testConfig) { c.fakeClock = true }
+}
+
+// withSink publishes the server's events to sink.
+func withSink(sink events.EventSink) testOption {
+	return func(c *testConfig) { c.sink = sink }
+}
+
+// withStore hands the server wrap's result instead of the bare badger store.
+func withStore(wrap func(storage.Store, *chaos.Engine) storage.Store) testOption {
+	return func(c *testConfig) { c.wrap = wrap }
+}
+
+// withServerOptions passes opts to server.New after WithChaos and WithClock.
+func withServerOptions(opts ...server.Option) testOption {
+	return func(c *testConfig) { c.serverOpts = append(c.serverOpts, opts...) }
+}
+
+// newTestServer opens a badger store under t.TempDir and builds a server on
+// it with its own chaos engine. The store is closed when the test ends. Without
+// withFakeClock the server runs on the wall clock.
+func newTestServer(t *testing.T, opts ...testOption) *testEnv {
+	t.Helper()
+	var cfg testConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	badger, err := storage.NewBadgerStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("open badger: %v", err)
+	}
+	t.Cleanup(func() { badger.Close() })
+
+	env := &testEnv{store: badger}
+	var engineOpts []chaos.Option
+	var serverOpts []server.Option
+	if cfg.fakeClock {
+		env.clock = clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+		engineOpts = append(engineOpts, chaos.WithClock(env.clock))
+		serverOpts = append(serverOpts, server.WithClock(env.clock))
+	}
+	env.engine = chaos.NewEngine(engineOpts...)
+	if cfg.wrap != nil {
+		env.store = cfg.wrap(badger, env.engine)
+	}
+	serverOpts = append(append(serverOpts, server.WithChaos(env.engine)), cfg.serverOpts...)
+	env.srv = server.New(env.store, cfg.sink, serverOpts...)
+	return env
+}
+
+// handler returns the HTTP shim in front of the server.
+func (e *testEnv) handler() http.Handler {
+	return api.NewHTTPHandler(e.srv, e.engine, chaos.NewScenarioRunner(e.engine))
+}
@@ -0,0 +1,671 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/inventory"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+	proto "github.com/devghori1264/aerophoenix/flyd-sim/internal/proto"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/server"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newInventory(t *testing.T, strategy inventory.Strategy) *inventory.Inventory {
+	t.Helper()
+	inv, err := inventory.New(&inventory.Config{
+		Strategy: strategy,
+		Regions: []inventory.Region{{
+			Code: "iad",
+			Hosts: []inventory.Host{
+				{ID: "iad-1", Capacity: inventory.Resources{CPUs: 4, MemoryMB: 4096}},
+				{ID: "iad-2", Capacity: inventory.Resources{CPUs: 4, MemoryMB: 4096}},
+			},
+		}, {
+			Code: "ord",
+			Name: "Chicago, Illinois (US)",
+		}, {
+			Code:  "ams",
+			Hosts: []inventory.Host{{ID: "ams-1", Capacity: inventory.Resources{CPUs: 8, MemoryMB: 8192}}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("new inventory: %v", err)
+	}
+	return inv
+}
+
+func TestPlacementStrategies(t *testing.T) {
+	small := inventory.Resources{CPUs: 1, MemoryMB: 512}
+	for strategy, want := range map[inventory.Strategy][]string{
+		inventory.StrategyBinpack: {"iad-1", "iad-1", "iad-1"},
+		inventory.StrategySpread:  {"iad-1", "iad-2", "iad-1"},
+	} {
+		inv := newInventory(t, strategy)
+		for i, host := range want {
+			got, err := inv.Place(string(rune('a'+i)), "iad", inventory.Request{Resources: small})
+			if err != nil {
+				t.Fatalf("%s: place %d: %v", strategy, i, err)
+			}
+			if got != host {
+				t.Fatalf("%s: expected machine %d on %s, got %s", strategy, i, host, got)
+			}
+		}
+	}
+}
+
+func TestCreateRejectsWhenRegionIsFull(t *testing.T) {
+	s := newTestServer(t, withServerOptions(server.WithInventory(newInventory(t, inventory.StrategyBinpack)))).srv
+	ctx := context.Background()
+	big := &proto.Guest{Cpus: 4, MemoryMb: 2048}
+
+	for i := 0; i < 2; i++ {
+		res, err := s.CreateMachine(ctx, &proto.CreateRequest{Name: "big", Region: "iad", Guest: big})
+		if err != nil {
+			t.Fatalf("create %d: %v", i, err)
+		}
+		g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: res.Id})
+		if g.HostId == "" {
+			t.Fatalf("expected machine %d to be placed on a host", i)
+		}
+	}
+	_, err := s.CreateMachine(ctx, &proto.CreateRequest{Name: "big", Region: "iad", Guest: big})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted once iad is full, got %v", err)
+	}
+	_, err = s.CreateMachine(ctx, &proto.CreateRequest{Name: "big", Region: "ord", Guest: big})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted for a region without hosts, got %v", err)
+	}
+	_, err = s.CreateMachine(ctx, &proto.CreateRequest{Name: "typo", Region: "iadd"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for a region missing from the catalog, got %v", err)
+	}
+}
+
+func TestMigrateMovesPlacementBetweenRegions(t *testing.T) {
+	s := newTestServer(t, withServerOptions(server.WithInventory(newInventory(t, inventory.StrategyBinpack)))).srv
+	ctx := context.Background()
+	res, err := s.CreateMachine(ctx, &proto.CreateRequest{Name: "web", Region: "iad", Guest: &proto.Guest{Cpus: 2}})
+	if err != nil {
+		t.Fatalf("create err: %v", err)
+	}
+
+	if _, err := s.MigrateMachine(ctx, &proto.MigrateRequest{Id: res.Id, TargetRegion: "nrt"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for an unknown target, got %v", err)
+	}
+	if _, err := s.MigrateMachine(ctx, &proto.MigrateRequest{Id: res.Id, TargetRegion: "ams"}); err != nil {
+		t.Fatalf("migrate err: %v", err)
+	}
+	g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: res.Id})
+	if g.Region != "ams" || g.HostId != "ams-1" {
+		t.Fatalf("expected machine on ams-1, got %s/%s", g.Region, g.HostId)
+	}
+
+	regions, err := s.ListRegions(ctx, &proto.ListRegionsRequest{})
+	if err != nil {
+		t.Fatalf("list regions: %v", err)
+	}
+	used := map[string]int32{}
+	for _, r := range regions.Regions {
+		used[r.Code] = r.Used.GetCpus()
+	}
+	if len(regions.Regions) != 3 || used["iad"] != 0 || used["ams"] != 2 {
+		t.Fatalf("expected capacity freed in iad and used in ams, got %v", used)
+	}
+}
+
+func TestMigrateWithoutHostReservesNothing(t *testing.T) {
+	s := newTestServer(t, withServerOptions(server.WithInventory(newInventory(t, inventory.StrategyBinpack)))).srv
+	ctx := context.Background()
+	var ids []string
+	for i := 0; i < 2; i++ {
+		res, err := s.CreateMachine(ctx, &proto.CreateRequest{Name: "web", Region: "iad", Guest: &proto.Guest{Cpus: 1}})
+		if err != nil {
+			t.Fatalf("create err: %v", err)
+		}
+		ids = append(ids, res.Id)
+	}
+	if _, err := s.StopMachine(ctx, &proto.ActionRequest{Id: ids[0]}); err != nil {
+		t.Fatalf("stop err: %v", err)
+	}
+	if _, err := s.DestroyMachine(ctx, &proto.ActionRequest{Id: ids[1]}); err != nil {
+		t.Fatalf("destroy err: %v", err)
+	}
+
+	if _, err := s.MigrateMachine(ctx, &proto.MigrateRequest{Id: ids[1], TargetRegion: "ams"}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition migrating a destroyed machine, got %v", err)
+	}
+	if _, err := s.MigrateMachine(ctx, &proto.MigrateRequest{Id: ids[0], TargetRegion: "ams"}); err != nil {
+		t.Fatalf("migrate stopped err: %v", err)
+	}
+	g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: ids[0]})
+	if g.Region != "ams" || g.Status != "stopped" || g.HostId != "" {
+		t.Fatalf("expected stopped machine moved to ams without a host, got %s/%s on %q", g.Region, g.Status, g.HostId)
+	}
+	for _, h := range s.Hosts() {
+		if h.Used.CPUs != 0 {
+			t.Fatalf("expected no capacity reserved, got %d cpus used on %s", h.Used.CPUs, h.ID)
+		}
+	}
+
+	// It is placed in its new region when started.
+	if _, err := s.StartMachine(ctx, &proto.ActionRequest{Id: ids[0]}); err != nil {
+		t.Fatalf("start err: %v", err)
+	}
+	if g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: ids[0]}); g.HostId != "ams-1" {
+		t.Fatalf("expected machine started on ams-1, got %q", g.HostId)
+	}
+}
+
+func TestDrainAndFailHost(t *testing.T) {
+	sink := &recordingSink{}
+	s := newTestServer(t, withSink(sink), withServerOptions(server.WithInventory(newInventory(t, inventory.StrategyBinpack)))).srv
+	ctx := context.Background()
+	var ids []string
+	for _, policy := range []string{"always", "no"} {
+		res, err := s.CreateMachine(ctx, &proto.CreateRequest{
+			Name:          policy,
+			Region:        "iad",
+			Guest:         &proto.Guest{Cpus: 2},
+			RestartPolicy: &proto.RestartPolicy{Policy: policy},
+		})
+		if err != nil {
+			t.Fatalf("create err: %v", err)
+		}
+		ids = append(ids, res.Id)
+	}
+	always, never := ids[0], ids[1]
+
+	drained, err := s.DrainHost(ctx, &proto.HostRequest{HostId: "iad-1"})
+	if err != nil {
+		t.Fatalf("drain err: %v", err)
+	}
+	if len(drained.Moved) != 2 || len(drained.Stranded) != 0 || drained.State != "cordoned" {
+		t.Fatalf("expected both machines drained off a cordoned host, got %+v", drained)
+	}
+	for _, id := range ids {
+		if g := waitForStatus(t, s, id, "running"); g.HostId != "iad-2" {
+			t.Fatalf("expected %s running on iad-2, got %s on %s", id, g.Status, g.HostId)
+		}
+	}
+	if _, err := s.UncordonHost(ctx, &proto.HostRequest{HostId: "iad-1"}); err != nil {
+		t.Fatalf("uncordon err: %v", err)
+	}
+
+	failed, err := s.FailHost(ctx, &proto.HostRequest{HostId: "iad-2"})
+	if err != nil {
+		t.Fatalf("fail err: %v", err)
+	}
+	if len(failed.Moved) != 1 || failed.Moved[0] != always || len(failed.Stranded) != 1 || failed.Stranded[0] != never {
+		t.Fatalf("expected only the always-restart machine rescheduled, got %+v", failed)
+	}
+	if g := waitForStatus(t, s, always, "running"); g.HostId != "iad-1" || g.RestartCount != 1 {
+		t.Fatalf("expected rescheduled machine running on iad-1, got %s on %s with %d restarts", g.Status, g.HostId, g.RestartCount)
+	}
+	if g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: never}); g.Status != "lost" || g.HostId != "" {
+		t.Fatalf("expected machine left lost without a host, got %s on %q", g.Status, g.HostId)
+	}
+	if _, err := s.CordonHost(ctx, &proto.HostRequest{HostId: "iad-2"}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition cordoning a failed host, got %v", err)
+	}
+
+	for suffix, want := range map[string]int{
+		"iad-1.draining": 1, "iad-1.drained": 1, "iad-2.failed": 1,
+		".migrated": 2, ".lost": 2, ".rescheduled": 1,
+	} {
+		if got := sink.count(suffix); got != want {
+			t.Fatalf("expected %d %s events, got %d", want, suffix, got)
+		}
+	}
+}
+
+func TestCapacityQueuePlacesInArrivalOrderAsCapacityFrees(t *testing.T) {
+	sink := &recordingSink{}
+	s := newTestServer(t, withSink(sink), withServerOptions(
+		server.WithInventory(newInventory(t, inventory.StrategyBinpack)),
+		server.WithCapacityQueue())).srv
+	ctx := context.Background()
+	create := func() *proto.CreateResponse {
+		res, err := s.CreateMachine(ctx, &proto.CreateRequest{Name: "big", Region: "iad", Guest: &proto.Guest{Cpus: 4}})
+		if err != nil {
+			t.Fatalf("create err: %v", err)
+		}
+		return res
+	}
+	a, b := create(), create()
+	first, second := create(), create()
+	if first.Status != "pending" || first.StatusReason != "waiting_for_capacity" || second.StatusReason != "waiting_for_capacity" {
+		t.Fatalf("expected machines queued once iad is full, got %+v and %+v", first, second)
+	}
+	regions, _ := s.ListRegions(ctx, &proto.ListRegionsRequest{})
+	if regions.Regions[0].Queued != 2 {
+		t.Fatalf("expected 2 machines queued in iad, got %d", regions.Regions[0].Queued)
+	}
+
+	// Stopping a frees a host; the machine queued first goes first.
+	waitForStatus(t, s, a.Id, "running")
+	if _, err := s.StopMachine(ctx, &proto.ActionRequest{Id: a.Id}); err != nil {
+		t.Fatalf("stop err: %v", err)
+	}
+	if g := waitForStatus(t, s, first.Id, "running"); g.Status != "running" || g.HostId == "" {
+		t.Fatalf("expected first queued machine placed and running, got %s on %q", g.Status, g.HostId)
+	}
+	if g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: second.Id}); g.StatusReason != "waiting_for_capacity" {
+		t.Fatalf("expected second queued machine still waiting, got %s/%s", g.Status, g.StatusReason)
+	}
+
+	waitForStatus(t, s, b.Id, "running")
+	if _, err := s.DestroyMachine(ctx, &proto.ActionRequest{Id: b.Id}); err != nil {
+		t.Fatalf("destroy err: %v", err)
+	}
+	if g := waitForStatus(t, s, second.Id, "running"); g.Status != "running" || g.StatusReason != "" {
+		t.Fatalf("expected queued machine running after destroy, got %s/%s", g.Status, g.StatusReason)
+	}
+	if g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: b.Id}); g.Status != "terminated" || g.HostId != "" {
+		t.Fatalf("expected destroyed machine terminated without a host, got %s on %q", g.Status, g.HostId)
+	}
+	if _, err := s.StartMachine(ctx, &proto.ActionRequest{Id: b.Id}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition starting a destroyed machine, got %v", err)
+	}
+	if n := sink.count(".placed"); n != 2 {
+		t.Fatalf("expected 2 machine.placed events, got %d", n)
+	}
+}
+
+func TestCapacityQueuePlacesHigherClassFirst(t *testing.T) {
+	s := newTestServer(t, withServerOptions(
+		server.WithInventory(newInventory(t, inventory.StrategyBinpack)),
+		server.WithCapacityQueue())).srv
+	ctx := context.Background()
+	create := func(class string) *proto.CreateResponse {
+		res, err := s.CreateMachine(ctx, &proto.CreateRequest{Name: class, Region: "iad", Guest: &proto.Guest{Cpus: 4}, PriorityClass: class})
+		if err != nil {
+			t.Fatalf("create %s: %v", class, err)
+		}
+		return res
+	}
+	// Critical machines fill iad so nothing queued can preempt them.
+	a, _ := create("critical"), create("critical")
+	spot, web := create("preemptible"), create("standard")
+	if spot.StatusReason != "waiting_for_capacity" || web.StatusReason != "waiting_for_capacity" {
+		t.Fatalf("expected machines queued once iad is full, got %+v and %+v", spot, web)
+	}
+
+	// The standard machine is placed first although it was queued last.
+	waitForStatus(t, s, a.Id, "running")
+	if _, err := s.StopMachine(ctx, &proto.ActionRequest{Id: a.Id}); err != nil {
+		t.Fatalf("stop err: %v", err)
+	}
+	if g := waitForStatus(t, s, web.Id, "running"); g.Status != "running" || g.HostId == "" {
+		t.Fatalf("expected standard machine placed and running, got %s on %q", g.Status, g.HostId)
+	}
+	if g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: spot.Id}); g.StatusReason != "waiting_for_capacity" {
+		t.Fatalf("expected preemptible machine still queued, got %s/%s", g.Status, g.StatusReason)
+	}
+}
+
+func TestPreemptionStopsLowerClassAfterGrace(t *testing.T) {
+	sink := &recordingSink{}
+	s := newTestServer(t, withSink(sink), withServerOptions(
+		server.WithInventory(newInventory(t, inventory.StrategyBinpack)),
+		server.WithPreemptionGrace(200*time.Millisecond))).srv
+	ctx := context.Background()
+	create := func(class string) (*proto.CreateResponse, error) {
+		return s.CreateMachine(ctx, &proto.CreateRequest{Name: class, Region: "iad", Guest: &proto.Guest{Cpus: 4}, PriorityClass: class})
+	}
+	var spot []string
+	for i := 0; i < 2; i++ {
+		res, err := create("preemptible")
+		if err != nil {
+			t.Fatalf("create spot %d: %v", i, err)
+		}
+		spot = append(spot, res.Id)
+	}
+	if _, err := create("preemptible"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted for a preemptible machine in a full region, got %v", err)
+	}
+
+	web, err := create("standard")
+	if err != nil {
+		t.Fatalf("create standard: %v", err)
+	}
+	if web.Status != "pending" || web.StatusReason != "waiting_for_preemption" {
+		t.Fatalf("expected standard machine waiting for preemption, got %+v", web)
+	}
+	// Its victims were picked in iad, so it cannot move elsewhere meanwhile.
+	if _, err := s.MigrateMachine(ctx, &proto.MigrateRequest{Id: web.Id, TargetRegion: "ams"}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition migrating a machine waiting for preemption, got %v", err)
+	}
+	if n := sink.count(".preempted"); n != 1 {
+		t.Fatalf("expected one machine.preempted event, got %d", n)
+	}
+	// The victim keeps its host until the grace period ends.
+	time.Sleep(50 * time.Millisecond)
+	stopped := 0
+	for _, id := range spot {
+		if g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: id}); g.Status == "stopped" {
+			stopped++
+		}
+	}
+	if stopped != 0 {
+		t.Fatalf("expected no machine stopped during the grace period, got %d", stopped)
+	}
+
+	if g := waitForStatus(t, s, web.Id, "running"); g.Status != "running" || g.HostId == "" {
+		t.Fatalf("expected standard machine running after preemption, got %s on %q", g.Status, g.HostId)
+	}
+	for _, id := range spot {
+		g, _ := s.GetMachine(ctx, &proto.GetRequest{Id: id})
+		if g.Status == "stopped" {
+			stopped++
+			if g.StatusReason != "preempted" || g.HostId != "" {
+				t.Fatalf("expected preempted machine stopped without a host, got %s/%s on %q", g.Status, g.StatusReason, g.HostId)
+			}
+		}
+	}
+	if stopped != 1 {
+		t.Fatalf("expected exactly one machine preempted, got %d", stopped)
+	}
+}
+
+func TestDestroyedPreemptorSparesVictims(t *testing.T) {
+	sink := &recordingSink{}
+	s := newTestServer(t, withSink(sink), withServerOptions(
+		server.WithInventory(newInventory(t, inventory.StrategyBinpack)),
+		server.WithPreemptionGrace(100*time.Millisecond))).srv
+	ctx := context.Background()
+	create := func(class string) (*proto.CreateResponse, error) {
+		return s.CreateMachine(ctx, &proto.CreateRequest{Name: class, Region: "iad", Guest: &proto.Guest{Cpus: 4}, PriorityClass: class})
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := create("preemptible"); err != nil {
+			t.Fatalf("create spot %d: %v", i, err)
+		}
+	}
+	critical, err := create("critical")
+	if err != nil || critical.StatusReason != "waiting_for_preemption" {
+		t.Fatalf("expected critical machine waiting for preemption, got %+v, %v", critical, err)
+	}
+	if _, err := s.DestroyMachine(ctx, &proto.ActionRequest{Id: critical.Id}); err != nil {
+		t.Fatalf("destroy err: %v", err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if n := sink.count(".stop"); n != 0 {
+		t.Fatalf("expected no victim stopped once the preemptor was destroyed, got %d", n)
+	}
+	// The spared victims are candidates again.
+	again, err := create("critical")
+	if err != nil || again.StatusReason != "waiting_for_preemption" {
+		t.Fatalf("expected a new preemption to be possible, got %+v, %v", again, err)
+	}
+}
+
+func zonedInventory(t *testing.T) *inventory.Inventory {
+	t.Helper()
+	size := inventory.Resources{CPUs: 4, MemoryMB: 4096}
+	inv, err := inventory.New(&inventory.Config{
+		Regions: []inventory.Region{{
+			Code: "fra",
+			Hosts: []inventory.Host{
+				{ID: "fra-1", Zone: "fra-a", Capacity: size},
+				{ID: "fra-2", Zone: "fra-a", Capacity: size},
+				{ID: "fra-3", Zone: "fra-b", Capacity: size},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("new inventory: %v", err)
+	}
+	return inv
+}
+
+func TestAntiAffinityAndHostPinning(t *testing.T) {
+	inv := zonedInventory(t)
+	db := inventory.Request{
+		Resources:    inventory.Resources{CPUs: 1, MemoryMB: 256},
+		Labels:       map[string]string{"app": "db"},
+		AntiAffinity: map[string]string{"app": "db"},
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		host, err := inv.Place(fmt.Sprintf("db-%d", i), "fra", db)
+		if err != nil {
+			t.Fatalf("place db-%d: %v", i, err)
+		}
+		if seen[host] {
+			t.Fatalf("db-%d shares %s with another db machine", i, host)
+		}
+		seen[host] = true
+	}
+
+	_, err := inv.Place("db-3", "fra", db)
+	var unsat *inventory.UnsatisfiableError
+	if !errors.As(err, &unsat) {
+		t.Fatalf("expected UnsatisfiableError with every host taken, got %v", err)
+	}
+	if len(unsat.Violations) != 3 || unsat.Violations[0].Constraint != inventory.ConstraintAntiAffinity {
+		t.Fatalf("expected an anti-affinity violation per host, got %+v", unsat.Violations)
+	}
+
+	// Anti-affinity is symmetric: a plain app=db machine avoids the hosts
+	// whose machines refuse it.
+	plain := inventory.Request{Resources: db.Resources, Labels: db.Labels}
+	if _, err := inv.Place("db-plain", "fra", plain); !errors.As(err, &unsat) {
+		t.Fatalf("expected UnsatisfiableError for a machine the others repel, got %v", err)
+	}
+
+	pinned := inventory.Request{Resources: db.Resources, Host: "fra-2"}
+	if host, err := inv.Place("pinned", "fra", pinned); err != nil || host != "fra-2" {
+		t.Fatalf("expected pinned machine on fra-2, got %q, %v", host, err)
+	}
+	pinned.Host = "iad-1"
+	if _, err := inv.Place("elsewhere", "fra", pinned); !errors.As(err, &unsat) || unsat.Violations[0].Constraint != inventory.ConstraintHost {
+		t.Fatalf("expected a host violation for a pin outside the region, got %v", err)
+	}
+}
+
+func TestSpreadAcrossZones(t *testing.T) {
+	inv := zonedInventory(t)
+	web := inventory.Request{
+		Resources: inventory.Resources{CPUs: 1, MemoryMB: 256},
+		Labels:    map[string]string{"app": "web"},
+		SpreadBy:  "app",
+	}
+	zoneOf := map[string]string{"fra-1": "fra-a", "fra-2": "fra-a", "fra-3": "fra-b"}
+	perZone := map[string]int{}
+	for i := 0; i < 4; i++ {
+		host, err := inv.Place(fmt.Sprintf("web-%d", i), "fra", web)
+		if err != nil {
+			t.Fatalf("place web-%d: %v", i, err)
+		}
+		perZone[zoneOf[host]]++
+	}
+	// Binpack alone would put all four on fra-1.
+	if perZone["fra-a"] != 2 || perZone["fra-b"] != 2 {
+		t.Fatalf("expected two web machines per zone, got %v", perZone)
+	}
+}
+
+func TestUnsatisfiableCreateReturnsViolations(t *testing.T) {
+	s := newTestServer(t, withServerOptions(server.WithInventory(newInventory(t, inventory.StrategyBinpack)))).srv
+	ctx := context.Background()
+
+	_, err := s.CreateMachine(ctx, &proto.CreateRequest{
+		Name:        "pinned",
+		Region:      "iad",
+		Constraints: &proto.Constraints{Host: "ams-1"},
+	})
+	st := status.Convert(err)
+	if st.Code() != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+	var pf *errdetails.PreconditionFailure
+	for _, d := range st.Details() {
+		if v, ok := d.(*errdetails.PreconditionFailure); ok {
+			pf = v
+		}
+	}
+	if pf == nil || len(pf.Violations) != 1 || pf.Violations[0].Type != "host" || pf.Violations[0].Subject != "ams-1" {
+		t.Fatalf("expected one host violation for ams-1, got %v", st.Details())
+	}
+
+	_, err = s.CreateMachine(ctx, &proto.CreateRequest{
+		Name:        "spread",
+		Region:      "iad",
+		Constraints: &proto.Constraints{SpreadBy: "app"},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for spread_by without the label, got %v", err)
+	}
+}
+
+func TestRTTMatrixFromCoordinatesAndOverrides(t *testing.T) {
+	inv, err := inventory.New(&inventory.Config{
+		Regions: []inventory.Region{
+			{Code: "iad", Latitude: 39.03, Longitude: -77.49},
+			{Code: "ams", Latitude: 52.37, Longitude: 4.90},
+			{Code: "ord", Latitude: 41.98, Longitude: -87.90},
+		},
+		RTTMs: map[string]map[string]float64{"iad": {"ord": 18}},
+	})
+	if err != nil {
+		t.Fatalf("new inventory: %v", err)
+	}
+	if d := inv.RTT("iad", "iad"); d != time.Millisecond {
+		t.Fatalf("expected 1ms within a region, got %s", d)
+	}
+	// About 6,200 km apart, so roughly 95ms over real fibre routes.
+	if d := inv.RTT("iad", "ams"); d < 85*time.Millisecond || d > 105*time.Millisecond || d != inv.RTT("ams", "iad") {
+		t.Fatalf("expected a symmetric ~95ms iad<->ams, got %s and %s", d, inv.RTT("ams", "iad"))
+	}
+	if inv.RTT("ord", "iad") != 18*time.Millisecond {
+		t.Fatalf("expected the iad->ord override to apply both ways, got %s", inv.RTT("ord", "iad"))
+	}
+}
+
+func TestCrashWithoutRestartReleasesHost(t *testing.T) {
+	env := newTestServer(t, withServerOptions(server.WithInventory(newInventory(t, inventory.StrategyBinpack))))
+	s := env.srv
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	create := func() (*proto.CreateResponse, error) {
+		return s.CreateMachine(ctx, &proto.CreateRequest{
+			Name:          "big",
+			Region:        "iad",
+			Guest:         &proto.Guest{Cpus: 4},
+			RestartPolicy: &proto.RestartPolicy{Policy: "no"},
+		})
+	}
+	a, err := create()
+	if err != nil {
+		t.Fatalf("create err: %v", err)
+	}
+	if _, err := create(); err != nil {
+		t.Fatalf("create err: %v", err)
+	}
+	if _, err := create(); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted once iad is full, got %v", err)
+	}
+	waitForStatus(t, s, a.Id, "running")
+
+	if _, err := env.engine.Add(chaos.Fault{
+		Kind:      chaos.KindCrash,
+		Scope:     chaos.Scope{MachineID: a.Id},
+		MTBFMs:    1,
+		ExitCodes: []int{1},
+	}); err != nil {
+		t.Fatalf("add fault: %v", err)
+	}
+	go s.RunCrashInjector(ctx, 20*time.Millisecond)
+
+	if g := waitForStatus(t, s, a.Id, "crashed"); g.Status != "crashed" || g.HostId != "" {
+		t.Fatalf("expected crashed machine without a host, got %s on %q", g.Status, g.HostId)
+	}
+	if _, err := create(); err != nil {
+		t.Fatalf("expected the freed host to take a new machine, got %v", err)
+	}
+}
+
+// latencySink keeps the latency_ms of every machine.latency event.
+type latencySink struct {
+	mu      sync.Mutex
+	samples []float64
+}
+
+func (l *latencySink) Publish(_ context.Context, subject string, data []byte) error {
+	if !strings.HasSuffix(subject, ".latency") {
+		return nil
+	}
+	var ev struct {
+		LatencyMs float64 `json:"latency_ms"`
+	}
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples = append(l.samples, ev.LatencyMs)
+	return nil
+}
+
+func (l *latencySink) Close() error { return nil }
+
+func (l *latencySink) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.samples)
+}
+
+func TestLatencySamplesAreReproducibleWithSeed(t *testing.T) {
+	run := func() []float64 {
+		sink := &latencySink{}
+		env := newTestServer(t, withFakeClock(), withSink(sink))
+		if err := env.store.SaveMachine(context.Background(), &models.Machine{ID: "m1", Region: "ams", Status: models.StatusRunning}); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		env.engine.SetLatency("ams", 100*time.Millisecond, 0)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			env.srv.RunLatencySampler(ctx, "", time.Second)
+			close(done)
+		}()
+		for i := 1; i <= 4; i++ {
+			env.clock.BlockUntil(1)
+			env.clock.Advance(time.Second)
+			deadline := time.Now().Add(time.Second)
+			for sink.len() < i {
+				if time.Now().After(deadline) {
+					t.Fatalf("expected %d latency samples, got %d", i, sink.len())
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+		cancel()
+		<-done
+		return sink.samples
+	}
+
+	first, second := run(), run()
+	for i, ms := range first {
+		if ms < 90 || ms > 110 {
+			t.Fatalf("expected sample %d within 10%% of 100ms, got %v", i, ms)
+		}
+		if second[i] != ms {
+			t.Fatalf("runs with the same seed diverged at sample %d: %v and %v", i, ms, second[i])
+		}
+	}
+}
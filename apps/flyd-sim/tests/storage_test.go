@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/chaos"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/models"
+	"github.com/devghori1264/aerophoenix/flyd-sim/internal/storage"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// memKV is the part of a JetStream KV bucket KVMirror writes to.
+type memKV struct {
+	jetstream.KeyValue
+	mu      sync.Mutex
+	values  map[string][]byte
+	puts    map[string]int
+	deletes map[string]int
+}
+
+func newMemKV() *memKV {
+	return &memKV{values: map[string][]byte{}, puts: map[string]int{}, deletes: map[string]int{}}
+}
+
+func (kv *memKV) Put(_ context.Context, key string, value []byte) (uint64, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.values[key] = value
+	kv.puts[key]++
+	return uint64(len(kv.values)), nil
+}
+
+func (kv *memKV) Delete(_ context.Context, key string, _ ...jetstream.KVDeleteOpt) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	delete(kv.values, key)
+	kv.deletes[key]++
+	return nil
+}
+
+// writes returns how often id was put or deleted.
+func (kv *memKV) writes(id string) int {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.puts[id] + kv.deletes[id]
+}
+
+// status returns the mirrored status of id, "deleted" for a tombstone and ""
+// when the key was never written.
+func (kv *memKV) status(t *testing.T, id string) string {
+	t.Helper()
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	data, ok := kv.values[id]
+	if !ok {
+		if kv.deletes[id] > 0 {
+			return "deleted"
+		}
+		return ""
+	}
+	var m models.Machine
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("decode mirrored %s: %v", id, err)
+	}
+	return m.Status
+}
+
+func TestKVMirrorTracksSavesAndDeletes(t *testing.T) {
+	kv := newMemKV()
+	var store storage.Store
+	var mirror *storage.KVMirror
+	newTestServer(t, withStore(func(s storage.Store, e *chaos.Engine) storage.Store {
+		store, mirror = s, storage.NewKVMirror(s, kv, e)
+		return mirror
+	}))
+	ctx := context.Background()
+
+	// Sync picks up what was stored before the mirror existed.
+	if err := store.SaveMachine(ctx, &models.Machine{ID: "old", Region: "iad", Status: "stopped"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := mirror.Sync(ctx); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if got := kv.status(t, "old"); got != "stopped" {
+		t.Fatalf("expected stopped synced, got %q", got)
+	}
+
+	if err := mirror.SaveMachine(ctx, &models.Machine{ID: "web", Region: "iad", Status: "running"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if got := kv.status(t, "web"); got != "running" {
+		t.Fatalf("expected running mirrored, got %q", got)
+	}
+	if err := mirror.DeleteMachine(ctx, "web"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got := kv.status(t, "web"); got != "deleted" {
+		t.Fatalf("expected a tombstone for the deleted machine, got %q", got)
+	}
+	if _, err := store.GetMachine(ctx, "web"); err == nil {
+		t.Fatalf("expected the machine gone from the store")
+	}
+}
+
+func TestKVMirrorHidesPartitionedMachines(t *testing.T) {
+	kv := newMemKV()
+	env := newTestServer(t, withStore(func(s storage.Store, e *chaos.Engine) storage.Store {
+		return storage.NewKVMirror(s, kv, e)
+	}))
+	ctx := context.Background()
+	for _, m := range []*models.Machine{
+		{ID: "a", Region: "iad", Status: "running"},
+		{ID: "b", Region: "ams", Status: "running"},
+		{ID: "c", Region: "ams", Status: "stopped"},
+	} {
+		if err := env.store.SaveMachine(ctx, m); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+
+	iad := env.engine.Partition("iad", 0)
+	if _, err := env.engine.Add(chaos.Fault{Kind: chaos.KindPartition, Scope: chaos.Scope{Region: "ams", MachineID: "c"}}); err != nil {
+		t.Fatalf("add fault: %v", err)
+	}
+	if got := kv.status(t, "a"); got != models.StatusUnreachable {
+		t.Fatalf("expected a mirrored as unreachable, got %q", got)
+	}
+	if got := kv.status(t, "b"); got != "running" {
+		t.Fatalf("expected b outside the partition left alone, got %q", got)
+	}
+	// Saves behind the partition are withheld until it heals.
+	if err := env.store.SaveMachine(ctx, &models.Machine{ID: "a", Region: "iad", Status: "stopped"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if got := kv.status(t, "a"); got != models.StatusUnreachable {
+		t.Fatalf("expected a still unreachable, got %q", got)
+	}
+
+	writes := map[string]int{"b": kv.writes("b"), "c": kv.writes("c")}
+	env.engine.Remove(iad.ID)
+	if got := kv.status(t, "a"); got != "stopped" {
+		t.Fatalf("expected a's saved state after heal, got %q", got)
+	}
+	for id, n := range writes {
+		if got := kv.writes(id); got != n {
+			t.Fatalf("expected %s outside the healed partition untouched, got %d writes after %d", id, got, n)
+		}
+	}
+	if got := kv.status(t, "c"); got != models.StatusUnreachable {
+		t.Fatalf("expected c still behind its own partition, got %q", got)
+	}
+}
+
+func TestKVMirrorTombstonesDestroyedMachines(t *testing.T) {
+	kv := newMemKV()
+	env := newTestServer(t, withStore(func(s storage.Store, e *chaos.Engine) storage.Store {
+		return storage.NewKVMirror(s, kv, e)
+	}))
+	ctx := context.Background()
+	if err := env.store.SaveMachine(ctx, &models.Machine{ID: "a", Region: "iad", Status: "running"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := env.store.SaveMachine(ctx, &models.Machine{ID: "a", Region: "iad", Status: models.StatusTerminated}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if got := kv.status(t, "a"); got != "deleted" {
+		t.Fatalf("expected a tombstone for the destroyed machine, got %q", got)
+	}
+
+	// A partition over the destroyed machine neither resurrects it as
+	// unreachable nor tombstones it again when it heals.
+	writes := kv.writes("a")
+	f := env.engine.Partition("iad", 0)
+	env.engine.Remove(f.ID)
+	if got := kv.writes("a"); got != writes {
+		t.Fatalf("expected the destroyed machine untouched by the partition, got %d writes after %d", got, writes)
+	}
+	if got := kv.status(t, "a"); got != "deleted" {
+		t.Fatalf("expected the tombstone kept, got %q", got)
+	}
+}
@@ -25,13 +25,23 @@ defmodule Orchestrator.Client do
     url("/get?id=#{id}") |> get()
   end
 
+  @doc """
+  Long-polls until the machine reaches `state`, fails for good or `timeout_ms`
+  passes. The result carries the machine and an "outcome" of "reached",
+  "failed" or "timeout".
+  """
+  def wait_machine(id, state, timeout_ms \\ 30_000) do
+    url("/v1/machines/#{id}/wait?state=#{state}&timeout=#{timeout_ms}ms")
+    |> get(timeout_ms + 5_000)
+  end
+
   # internal helpers
 
   defp url(path), do: "#{@base}#{path}"
 
-  defp get(url) do
+  defp get(url, receive_timeout \\ 5_000) do
     req = Finch.build(:get, url, @default_headers)
-    case Finch.request(req, Orchestrator.Finch, receive_timeout: 5_000) do
+    case Finch.request(req, Orchestrator.Finch, receive_timeout: receive_timeout) do
       {:ok, %Response{status: status, body: body}} when status in 200..299 ->
         {:ok, Jason.decode!(body)}
       {:ok, %Response{status: status}} ->
@@ -11,6 +11,18 @@ defmodule Orchestrator.FlydClient do
     call(:post, "/v1/machines/#{id}/stop", %{})
   end
 
+  def restart_machine(id) do
+    call(:post, "/v1/machines/#{id}/restart", %{})
+  end
+
+  def suspend_machine(id) do
+    call(:post, "/v1/machines/#{id}/suspend", %{})
+  end
+
+  def resume_machine(id) do
+    call(:post, "/v1/machines/#{id}/resume", %{})
+  end
+
   def migrate_machine(id, target_region) do
     call(:post, "/v1/machines/#{id}/migrate", %{target: target_region})
   end
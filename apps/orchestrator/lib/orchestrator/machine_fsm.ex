@@ -45,6 +45,10 @@ defmodule Orchestrator.MachineFSM do
     do_stop(state)
   end
 
+  def handle_call({:command, action}, _from, state) when action in ["restart", "suspend", "resume"] do
+    do_lifecycle(state, action)
+  end
+
   def handle_call({:command, "migrate", target}, _from, state) do
     do_migrate(state, target)
   end
@@ -54,6 +58,7 @@ defmodule Orchestrator.MachineFSM do
     case action do
       {:start} -> do_start(state)
       {:migrate, target} -> do_migrate(state, target)
+      {:lifecycle, action} -> do_lifecycle(state, action)
       _ -> {:noreply, state}
     end
   end
@@ -89,6 +94,27 @@ defmodule Orchestrator.MachineFSM do
     end
   end
 
+  # Restart and resume leave the machine starting until flyd reports it
+  # running; suspend pauses it with its memory snapshotted.
+  @lifecycle %{
+    "restart" => {&FlydClient.restart_machine/1, "starting", "restarted"},
+    "suspend" => {&FlydClient.suspend_machine/1, "suspended", "suspended"},
+    "resume" => {&FlydClient.resume_machine/1, "starting", "resumed"}
+  }
+
+  defp do_lifecycle(state, action) do
+    {call, status, event} = Map.fetch!(@lifecycle, action)
+    case call.(state.id) do
+      {:ok, resp} ->
+        persist_db_update(state.id, %{status: status, last_seen_at: DateTime.utc_now()})
+        persist_event(state.id, event, resp)
+        broadcast_update(state.id)
+        {:reply, {:ok, resp}, %{state | status: String.to_atom(status), retry_count: 0}}
+      {:error, _reason} ->
+        schedule_retry({:lifecycle, action}, state)
+    end
+  end
+
   defp do_migrate(state, target) do
     case FlydClient.migrate_machine(state.id, target) do
       {:ok, resp} ->
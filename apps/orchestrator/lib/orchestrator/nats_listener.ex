@@ -8,7 +8,7 @@ defmodule Orchestrator.NatsListener do
   def init(_) do
     case :gnat.start_link(%{host: @nats_url}) do
       {:ok, conn} ->
-        :gnat.sub(conn, self(), "machines.events")
+        :gnat.sub(conn, self(), "machines.events.>")
         :gnat.sub(conn, self(), "ui.actions")
         Logger.info("NATS connected and subscriptions set")
         {:ok, conn}
@@ -18,7 +18,7 @@ defmodule Orchestrator.NatsListener do
     end
   end
 
-  def handle_info({:msg, %{subject: "machines.events", body: body}}, conn) do
+  def handle_info({:msg, %{subject: "machines.events." <> _, body: body}}, conn) do
     case Jason.decode(body) do
       {:ok, payload} ->
         handle_machine_event(payload)
@@ -35,6 +35,12 @@ defmodule Orchestrator.NatsListener do
     {:noreply, conn}
   end
 
+  # Latency samples only feed the Predictor; they are too frequent to persist.
+  defp handle_machine_event(%{"event" => "machine.latency", "id" => id, "latency_ms" => ms})
+       when is_binary(id) and is_number(ms) do
+    Orchestrator.Predictor.record_sample(id, ms)
+  end
+
   defp handle_machine_event(payload) do
     id = payload["id"]
     :ok = Orchestrator.MachineManager.ensure_started(id, payload)
@@ -46,7 +52,7 @@ defmodule Orchestrator.NatsListener do
 
   defp handle_ui_action(%{"action" => action, "id" => id} = payload) do
     case action do
-      "restart" -> call_machine_cmd(id, "stop"); :timer.sleep(300); call_machine_cmd(id, "start")
+      action when action in ["restart", "suspend", "resume"] -> call_machine_cmd(id, action)
       "migrate" -> call_machine_cmd(id, "migrate", Map.get(payload, "target"))
       _ -> Logger.info("UI action ignored #{inspect(action)}")
     end
@@ -59,6 +65,7 @@ defmodule Orchestrator.NatsListener do
           "start" -> GenServer.call(pid, {:command, "start"})
           "stop" -> GenServer.call(pid, {:command, "stop"})
           "migrate" -> GenServer.call(pid, {:command, "migrate", arg})
+          action -> GenServer.call(pid, {:command, action})
         end
       [] ->
         Orchestrator.MachineManager.ensure_started(id)
@@ -58,13 +58,10 @@ defmodule Orchestrator.Router do
     |> send_resp(status, Jason.encode!(data, pretty: false))
   end
 
-  defp perform_action(id, "restart", _payload) do
+  defp perform_action(id, action, _payload) when action in ["restart", "suspend", "resume"] do
     Orchestrator.MachineManager.ensure_started(id)
     case Registry.lookup(Orchestrator.FSMRegistry, id) do
-      [{pid, _}] ->
-        GenServer.call(pid, {:command, "stop"})
-        :timer.sleep(250)
-        GenServer.call(pid, {:command, "start"})
+      [{pid, _}] -> GenServer.call(pid, {:command, action})
       _ -> :ok
     end
   end
@@ -0,0 +1,678 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// experiment is a chaos experiment file for `aeropctl chaos run`. Faults use
+// the same JSON shape as flyd-sim's POST /chaos/faults.
+//
+//	name: iad-partition
+//	baseline: 15s
+//	hold: 20s
+//	recovery_timeout: 60s
+//	faults:
+//	  - kind: partition
+//	    scope: {region: iad}
+//	hypotheses:
+//	  - name: all machines running again within 30s
+//	    recovered_within: 30s
+//	  - name: nothing crashes
+//	    max_events: {type: crashed, count: 0}
+//	  - name: no crash counted either
+//	    max_metric_increase: {name: flyd_machine_crash_total, max: 0}
+//
+// Machine status is taken from machines.events and from polling flyd-sim's
+// GET /get for every machine seen, so machines a partition makes unreachable,
+// which publish nothing, count as down until they answer as running again.
+type experiment struct {
+	Name            string                   `yaml:"name"`
+	Baseline        time.Duration            `yaml:"baseline"`
+	Hold            time.Duration            `yaml:"hold"`
+	RecoveryTimeout time.Duration            `yaml:"recovery_timeout"`
+	Faults          []map[string]interface{} `yaml:"faults"`
+	Hypotheses      []hypothesis             `yaml:"hypotheses"`
+}
+
+// hypothesis is one pass/fail check. Exactly one of its checks is set.
+type hypothesis struct {
+	Name string `yaml:"name"`
+	// RecoveredWithin passes when every machine is running again, and as
+	// many run as at baseline, within this long of the heal.
+	RecoveredWithin time.Duration `yaml:"recovered_within"`
+	// MaxEvents passes when at most Count events of Type were seen between
+	// injection and recovery.
+	MaxEvents *struct {
+		Type  string `yaml:"type"`
+		Count int    `yaml:"count"`
+	} `yaml:"max_events"`
+	// MaxMetricIncrease passes when the flyd-sim metric Name, summed over
+	// its labels, grew by at most Max between injection and recovery.
+	MaxMetricIncrease *struct {
+		Name string  `yaml:"name"`
+		Max  float64 `yaml:"max"`
+	} `yaml:"max_metric_increase"`
+}
+
+// checks is how many of h's checks are set.
+func (h hypothesis) checks() int {
+	n := 0
+	if h.RecoveredWithin > 0 {
+		n++
+	}
+	if h.MaxEvents != nil {
+		n++
+	}
+	if h.MaxMetricIncrease != nil {
+		n++
+	}
+	return n
+}
+
+func loadExperiment(path string) (*experiment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	exp := &experiment{
+		Baseline:        15 * time.Second,
+		Hold:            30 * time.Second,
+		RecoveryTimeout: 2 * time.Minute,
+	}
+	if err := yaml.Unmarshal(data, exp); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(exp.Faults) == 0 {
+		return nil, errors.New("experiment has no faults")
+	}
+	for i, h := range exp.Hypotheses {
+		if h.checks() != 1 {
+			return nil, fmt.Errorf("hypothesis %d: set exactly one of recovered_within, max_events and max_metric_increase", i+1)
+		}
+		if h.MaxMetricIncrease != nil && h.MaxMetricIncrease.Name == "" {
+			return nil, fmt.Errorf("hypothesis %d: max_metric_increase needs a metric name", i+1)
+		}
+		if h.Name == "" {
+			exp.Hypotheses[i].Name = fmt.Sprintf("hypothesis %d", i+1)
+		}
+	}
+	return exp, nil
+}
+
+func chaosCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "chaos",
+		Short: "Run chaos experiments against flyd-sim",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "run [experiment.yaml]",
+		Short: "Baseline, inject faults, heal and report time to recovery",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			exp, err := loadExperiment(args[0])
+			if err != nil {
+				logger.Errorf("chaos run failed: %v", err)
+				os.Exit(1)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			c := make(chan os.Signal, 1)
+			signal.Notify(c, os.Interrupt)
+			go func() {
+				<-c
+				cancel()
+			}()
+			passed, err := doChaosRun(ctx, exp)
+			if err != nil {
+				logger.Errorf("chaos run failed: %v", err)
+				os.Exit(1)
+			}
+			if !passed {
+				os.Exit(1)
+			}
+		},
+	})
+	return cmd
+}
+
+// observer tracks machine status from machines.events and GET /get polls,
+// and running counts from flyd.heartbeat, while an experiment runs.
+type observer struct {
+	mu sync.Mutex
+	// status is the last status each machine reported or was polled at;
+	// since is when it last changed.
+	status map[string]string
+	since  map[string]time.Time
+	// polledAt is when the latest complete poll of every machine began.
+	polledAt time.Time
+	// hosts holds the running count from each host's latest heartbeat.
+	hosts    map[string]int
+	hbAt     time.Time
+	counting bool
+	counts   map[string]int
+	events   int
+}
+
+func newObserver() *observer {
+	return &observer{
+		status: map[string]string{},
+		since:  map[string]time.Time{},
+		hosts:  map[string]int{},
+		counts: map[string]int{},
+	}
+}
+
+func (o *observer) handle(msg *nats.Msg) {
+	now := time.Now()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if msg.Subject == "flyd.heartbeat" {
+		var hb heartbeat
+		if err := json.Unmarshal(msg.Data, &hb); err == nil && hb.HostID != "" {
+			o.hosts[hb.HostID] = hb.ByStatus["running"]
+			o.hbAt = now
+		}
+		return
+	}
+	var ev struct {
+		Event  string `json:"event"`
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(msg.Data, &ev); err != nil || ev.ID == "" {
+		return
+	}
+	o.events++
+	if o.counting {
+		o.counts[strings.TrimPrefix(ev.Event, "machine.")]++
+	}
+	o.observeLocked(ev.ID, ev.Status, now)
+}
+
+// track starts following machine id before it has reported a status.
+func (o *observer) track(id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.status[id]; !ok {
+		o.status[id] = ""
+	}
+}
+
+func (o *observer) observe(id, status string, at time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observeLocked(id, status, at)
+}
+
+func (o *observer) observeLocked(id, status string, at time.Time) {
+	if status == "" {
+		return
+	}
+	if cur, ok := o.status[id]; !ok || status != cur {
+		o.status[id] = status
+		o.since[id] = at
+	}
+}
+
+func (o *observer) machineIDs() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ids := make([]string, 0, len(o.status))
+	for id := range o.status {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// poll asks flyd-sim for the status of every known machine each interval
+// until ctx ends. Machines behind a partition answer unreachable.
+func (o *observer) poll(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		started := time.Now()
+		for _, id := range o.machineIDs() {
+			var m struct {
+				Status string `json:"status"`
+			}
+			if err := flydRequest(ctx, http.MethodGet, "/get?id="+url.QueryEscape(id), nil, &m); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				continue
+			}
+			o.observe(id, m.Status, time.Now())
+		}
+		o.mu.Lock()
+		o.polledAt = started
+		o.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// snapshot returns the running count across hosts, when the last heartbeat
+// arrived, when the last complete poll began, the machines not running and
+// when the last one came back up.
+func (o *observer) snapshot() (running int, hbAt, polledAt time.Time, down []string, lastUp time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, n := range o.hosts {
+		running += n
+	}
+	for id, st := range o.status {
+		if st != "running" {
+			down = append(down, id)
+		} else if o.since[id].After(lastUp) {
+			lastUp = o.since[id]
+		}
+	}
+	sort.Strings(down)
+	return running, o.hbAt, o.polledAt, down, lastUp
+}
+
+// pollInterval is how often every machine's status is polled.
+const pollInterval = 500 * time.Millisecond
+
+func doChaosRun(ctx context.Context, exp *experiment) (bool, error) {
+	nc, err := nats.Connect(natsURL)
+	if err != nil {
+		return false, err
+	}
+	defer nc.Drain()
+
+	obs := newObserver()
+	for _, subj := range []string{"machines.events.>", "flyd.heartbeat"} {
+		if _, err := nc.Subscribe(subj, obs.handle); err != nil {
+			return false, err
+		}
+	}
+	// Machines in a steady state publish nothing, so start from the
+	// orchestrator's list.
+	if ids, err := listMachineIDs(ctx); err != nil {
+		logger.Warnf("list machines from orchestrator failed, following machines seen in events only: %v", err)
+	} else {
+		for _, id := range ids {
+			obs.track(id)
+		}
+	}
+	pollCtx, stopPolling := context.WithCancel(ctx)
+	defer stopPolling()
+	go obs.poll(pollCtx, pollInterval)
+
+	logger.Infof("Capturing %s baseline from %s", exp.Baseline, natsURL)
+	metricsStart, metricsErr := scrapeMetrics(ctx)
+	if err := sleepCtx(ctx, exp.Baseline); err != nil {
+		return false, err
+	}
+	baseRunning, baseHB, _, baseDown, _ := obs.snapshot()
+	if baseHB.IsZero() {
+		logger.Warnf("no heartbeats during baseline; recovery is judged on events and polls only")
+	}
+	obs.mu.Lock()
+	baseRate := float64(obs.events) / exp.Baseline.Seconds()
+	obs.counting = true
+	obs.mu.Unlock()
+	var baseMetrics, injectMetrics map[string]float64
+	if metricsErr == nil {
+		injectMetrics, metricsErr = scrapeMetrics(ctx)
+		baseMetrics = increase(metricsStart, injectMetrics)
+	}
+	if metricsErr != nil {
+		logger.Warnf("metrics unavailable from %s: %v", metricsURL, metricsErr)
+	}
+
+	injected := time.Now()
+	var faultIDs []string
+	heal := func() {
+		// Heal even if the run was interrupted.
+		hctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for _, id := range faultIDs {
+			if err := flydRequest(hctx, http.MethodDelete, "/chaos/faults/"+id, nil, nil); err != nil {
+				logger.Errorf("heal %s failed: %v", id, err)
+			}
+		}
+		faultIDs = nil
+	}
+	defer heal()
+	for _, f := range exp.Faults {
+		var added struct {
+			ID string `json:"id"`
+		}
+		if err := flydRequest(ctx, http.MethodPost, "/chaos/faults", f, &added); err != nil {
+			return false, fmt.Errorf("inject fault: %w", err)
+		}
+		faultIDs = append(faultIDs, added.ID)
+		logger.Infof("Injected %s: %v", added.ID, f)
+	}
+
+	if err := sleepCtx(ctx, exp.Hold); err != nil {
+		return false, err
+	}
+	heal()
+	healed := time.Now()
+	logger.Infof("Healed after %s, waiting up to %s for recovery", healed.Sub(injected).Round(time.Millisecond), exp.RecoveryTimeout)
+
+	// Recovered once a poll started after the heal, and every event since,
+	// has every machine that was not already down at baseline running and,
+	// when heartbeats are flowing, a heartbeat taken after the heal reports
+	// the baseline count.
+	res := result{}
+	deadline := time.NewTimer(exp.RecoveryTimeout)
+	defer deadline.Stop()
+	tick := time.NewTicker(250 * time.Millisecond)
+	defer tick.Stop()
+wait:
+	for {
+		running, hbAt, polledAt, down, lastUp := obs.snapshot()
+		down = without(down, baseDown)
+		if len(down) == 0 && polledAt.After(healed) && (baseHB.IsZero() || (hbAt.After(healed) && running >= baseRunning)) {
+			res.recovered = true
+			if lastUp.After(healed) {
+				res.recovery = lastUp.Sub(healed)
+			}
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-deadline.C:
+			break wait
+		case <-tick.C:
+		}
+	}
+	stopPolling()
+
+	obs.mu.Lock()
+	obs.counting = false
+	res.counts = make(map[string]int, len(obs.counts))
+	for k, v := range obs.counts {
+		res.counts[k] = v
+	}
+	obs.mu.Unlock()
+	_, _, _, down, _ := obs.snapshot()
+	res.down = without(down, baseDown)
+	if metricsErr == nil {
+		var end map[string]float64
+		if end, metricsErr = scrapeMetrics(ctx); metricsErr == nil {
+			res.metrics = increase(injectMetrics, end)
+		} else {
+			logger.Warnf("metrics unavailable from %s: %v", metricsURL, metricsErr)
+		}
+	}
+
+	name := exp.Name
+	if name == "" {
+		name = "-"
+	}
+	fmt.Printf("experiment\t%s\n", name)
+	fmt.Printf("baseline\t%d running, %d not running, %.2f events/s\n", baseRunning, len(baseDown), baseRate)
+	if baseMetrics != nil {
+		fmt.Printf("baseline metrics\t%s\n", formatIncreases(baseMetrics))
+	}
+	fmt.Printf("faults\t%d held for %s\n", len(exp.Faults), healed.Sub(injected).Round(time.Millisecond))
+	if res.recovered {
+		fmt.Printf("recovery\t%s\n", res.recovery.Round(time.Millisecond))
+	} else {
+		fmt.Printf("recovery\tnot within %s (%d machines down)\n", exp.RecoveryTimeout, len(res.down))
+	}
+	fmt.Printf("events\t%s\n", formatCounts(res.counts))
+	if res.metrics != nil {
+		fmt.Printf("metrics\t%s\n", formatIncreases(res.metrics))
+	}
+
+	verdicts, passed := evaluate(exp.Hypotheses, res)
+	fmt.Printf("\nHYPOTHESIS\tRESULT\tDETAIL\n")
+	for _, v := range verdicts {
+		outcome := "PASS"
+		if !v.ok {
+			outcome = "FAIL"
+		}
+		fmt.Printf("%s\t%s\t%s\n", v.name, outcome, v.detail)
+	}
+	return passed, nil
+}
+
+// result is what an experiment observed from injection to recovery.
+type result struct {
+	recovered bool
+	recovery  time.Duration
+	// down lists the machines still not running at the end.
+	down   []string
+	counts map[string]int
+	// metrics is how much each flyd-sim metric grew; nil when metrics
+	// could not be scraped.
+	metrics map[string]float64
+}
+
+type verdict struct {
+	name   string
+	ok     bool
+	detail string
+}
+
+// evaluate checks each hypothesis against res and reports whether all of
+// them passed.
+func evaluate(hs []hypothesis, res result) ([]verdict, bool) {
+	passed := true
+	out := make([]verdict, 0, len(hs))
+	for _, h := range hs {
+		v := verdict{name: h.Name}
+		switch {
+		case h.RecoveredWithin > 0:
+			v.ok = res.recovered && res.recovery <= h.RecoveredWithin
+			if res.recovered {
+				v.detail = fmt.Sprintf("recovered in %s", res.recovery.Round(time.Millisecond))
+			} else {
+				v.detail = fmt.Sprintf("not recovered, down: %s", strings.Join(res.down, ","))
+			}
+		case h.MaxEvents != nil:
+			n := res.counts[h.MaxEvents.Type]
+			v.ok = n <= h.MaxEvents.Count
+			v.detail = fmt.Sprintf("%d %s events (max %d)", n, h.MaxEvents.Type, h.MaxEvents.Count)
+		case h.MaxMetricIncrease != nil:
+			m := h.MaxMetricIncrease
+			if res.metrics == nil {
+				v.detail = "metrics unavailable"
+				break
+			}
+			d := res.metrics[m.Name]
+			v.ok = d <= m.Max
+			v.detail = fmt.Sprintf("%s +%g (max %g)", m.Name, d, m.Max)
+		}
+		if !v.ok {
+			passed = false
+		}
+		out = append(out, v)
+	}
+	return out, passed
+}
+
+// listMachineIDs returns the IDs of the machines the orchestrator knows.
+func listMachineIDs(ctx context.Context) ([]string, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(reqCtx, http.MethodGet, orchURL+"/api/v1/machines", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var machines []struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&machines); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(machines))
+	for _, m := range machines {
+		if m.ID != "" {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids, nil
+}
+
+// scrapeMetrics reads flyd-sim's Prometheus endpoint.
+func scrapeMetrics(ctx context.Context) (map[string]float64, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(reqCtx, http.MethodGet, metricsURL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return parseMetrics(resp.Body)
+}
+
+// parseMetrics sums each flyd_ sample in the Prometheus text format over its
+// labels. Histogram buckets are skipped.
+func parseMetrics(r io.Reader) (map[string]float64, error) {
+	out := map[string]float64{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name := line
+		if i := strings.IndexAny(line, "{ "); i >= 0 {
+			name = line[:i]
+		}
+		if !strings.HasPrefix(name, "flyd_") || strings.HasSuffix(name, "_bucket") {
+			continue
+		}
+		rest := line[len(name):]
+		if strings.HasPrefix(rest, "{") {
+			end := strings.LastIndex(rest, "}")
+			if end < 0 {
+				continue
+			}
+			rest = rest[end+1:]
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		out[name] += v
+	}
+	return out, sc.Err()
+}
+
+// increase is how much each metric changed from from to to, leaving out the
+// unchanged ones.
+func increase(from, to map[string]float64) map[string]float64 {
+	out := map[string]float64{}
+	for name, v := range to {
+		if d := v - from[name]; d != 0 {
+			out[name] = d
+		}
+	}
+	return out
+}
+
+func formatIncreases(m map[string]float64) string {
+	if len(m) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%+g", k, m[k]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func without(ids, drop []string) []string {
+	skip := make(map[string]bool, len(drop))
+	for _, id := range drop {
+		skip[id] = true
+	}
+	var out []string
+	for _, id := range ids {
+		if !skip[id] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// flydRequest calls the flyd-sim HTTP shim, sending body as JSON and
+// decoding the response into out when both are set.
+func flydRequest(ctx context.Context, method, path string, body, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(reqCtx, method, flydURL+path, r)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
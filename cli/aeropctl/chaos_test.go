@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeExperiment(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "experiment.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write experiment: %v", err)
+	}
+	return path
+}
+
+func TestLoadExperiment(t *testing.T) {
+	exp, err := loadExperiment(writeExperiment(t, `
+name: iad-partition
+hold: 5s
+faults:
+  - kind: partition
+    scope: {region: iad}
+hypotheses:
+  - recovered_within: 30s
+  - name: no crash counted
+    max_metric_increase: {name: flyd_machine_crash_total, max: 0}
+`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if exp.Baseline != 15*time.Second || exp.Hold != 5*time.Second || exp.RecoveryTimeout != 2*time.Minute {
+		t.Fatalf("expected default baseline and recovery timeout with the set hold, got %+v", exp)
+	}
+	if len(exp.Hypotheses) != 2 || exp.Hypotheses[0].Name != "hypothesis 1" || exp.Hypotheses[1].Name != "no crash counted" {
+		t.Fatalf("expected a default name for the unnamed hypothesis only, got %+v", exp.Hypotheses)
+	}
+
+	for name, body := range map[string]string{
+		"no faults": `
+hypotheses:
+  - recovered_within: 30s
+`,
+		"two checks": `
+faults: [{kind: partition, scope: {region: iad}}]
+hypotheses:
+  - recovered_within: 30s
+    max_events: {type: crashed, count: 0}
+`,
+		"no check": `
+faults: [{kind: partition, scope: {region: iad}}]
+hypotheses:
+  - name: empty
+`,
+		"metric without name": `
+faults: [{kind: partition, scope: {region: iad}}]
+hypotheses:
+  - max_metric_increase: {max: 0}
+`,
+	} {
+		if _, err := loadExperiment(writeExperiment(t, body)); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEvaluateHypotheses(t *testing.T) {
+	exp, err := loadExperiment(writeExperiment(t, `
+faults: [{kind: partition, scope: {region: iad}}]
+hypotheses:
+  - name: recovered
+    recovered_within: 10s
+  - name: no crashes
+    max_events: {type: crashed, count: 0}
+  - name: no crash counted
+    max_metric_increase: {name: flyd_machine_crash_total, max: 0}
+`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	verdicts, passed := evaluate(exp.Hypotheses, result{
+		recovered: true,
+		recovery:  3 * time.Second,
+		counts:    map[string]int{"reconciled": 2},
+		metrics:   map[string]float64{},
+	})
+	if !passed || len(verdicts) != 3 {
+		t.Fatalf("expected all three to pass, got %+v", verdicts)
+	}
+
+	verdicts, passed = evaluate(exp.Hypotheses, result{
+		down:   []string{"m-1"},
+		counts: map[string]int{"crashed": 1},
+	})
+	if passed {
+		t.Fatalf("expected failure")
+	}
+	for _, v := range verdicts {
+		if v.ok {
+			t.Fatalf("expected %q to fail, got %+v", v.name, v)
+		}
+	}
+	if !strings.Contains(verdicts[0].detail, "m-1") || verdicts[2].detail != "metrics unavailable" {
+		t.Fatalf("unexpected details %+v", verdicts)
+	}
+
+	_, passed = evaluate(exp.Hypotheses[:1], result{recovered: true, recovery: 11 * time.Second})
+	if passed {
+		t.Fatalf("expected a recovery slower than recovered_within to fail")
+	}
+}
+
+func TestParseMetrics(t *testing.T) {
+	m, err := parseMetrics(strings.NewReader(`# HELP flyd_machine_crash_total Machine crashes.
+# TYPE flyd_machine_crash_total counter
+flyd_machine_crash_total{region="iad",reason="oom"} 2
+flyd_machine_crash_total{region="ams",reason="label with } brace"} 1
+flyd_machines_running 4
+flyd_op_duration_seconds_bucket{le="0.1"} 9
+flyd_op_duration_seconds_count 9
+go_goroutines 12
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := map[string]float64{
+		"flyd_machine_crash_total":       3,
+		"flyd_machines_running":          4,
+		"flyd_op_duration_seconds_count": 9,
+	}
+	if len(m) != len(want) {
+		t.Fatalf("expected %v, got %v", want, m)
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Fatalf("expected %s=%g, got %v", k, v, m)
+		}
+	}
+	if d := increase(want, map[string]float64{"flyd_machine_crash_total": 5, "flyd_machines_running": 4}); len(d) != 1 || d["flyd_machine_crash_total"] != 2 {
+		t.Fatalf("unexpected increase %v", d)
+	}
+}
+
+func TestObserverPollsPartitionedMachines(t *testing.T) {
+	var healed atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/get" || r.URL.Query().Get("id") != "m-1" {
+			http.NotFound(w, r)
+			return
+		}
+		if healed.Load() {
+			w.Write([]byte(`{"id":"m-1","status":"running"}`))
+			return
+		}
+		w.Write([]byte(`{"id":"m-1","status":"unreachable"}`))
+	}))
+	defer srv.Close()
+	prev := flydURL
+	flydURL = srv.URL
+	defer func() { flydURL = prev }()
+
+	obs := newObserver()
+	obs.track("m-1")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go obs.poll(ctx, 10*time.Millisecond)
+
+	// A partitioned machine publishes no event but still counts as down.
+	waitFor := func(cond func(down []string) bool) {
+		t.Helper()
+		start := time.Now()
+		for time.Since(start) < 2*time.Second {
+			_, _, polledAt, down, _ := obs.snapshot()
+			if polledAt.After(start) && cond(down) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("observer did not reach the expected state")
+	}
+	waitFor(func(down []string) bool { return len(down) == 1 && down[0] == "m-1" })
+
+	healed.Store(true)
+	waitFor(func(down []string) bool { return len(down) == 0 })
+	if _, _, _, _, lastUp := obs.snapshot(); lastUp.IsZero() {
+		t.Fatalf("expected the machine's return to be timed")
+	}
+}
@@ -6,6 +6,7 @@ require (
 	github.com/nats-io/nats.go v1.47.0
 	github.com/spf13/cobra v1.10.1
 	go.uber.org/zap v1.27.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -3,10 +3,13 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -18,6 +21,9 @@ var (
 	logger  *zap.SugaredLogger
 	natsURL string
 	orchURL string
+	flydURL string
+	// metricsURL is flyd-sim's Prometheus endpoint.
+	metricsURL string
 )
 
 func main() {
@@ -37,12 +43,22 @@ func main() {
 			if orchURL == "" {
 				orchURL = "http://localhost:4001"
 			}
+			flydURL = os.Getenv("FLYD_URL")
+			if flydURL == "" {
+				flydURL = "http://localhost:8080"
+			}
+			metricsURL = os.Getenv("FLYD_METRICS_URL")
+			if metricsURL == "" {
+				metricsURL = "http://localhost:9090/metrics"
+			}
 		},
 	}
 
 	root.AddCommand(listCmd())
 	root.AddCommand(inspectCmd())
 	root.AddCommand(tailCmd())
+	root.AddCommand(hostsCmd())
+	root.AddCommand(chaosCmd())
 
 	if err := root.Execute(); err != nil {
 		logger.Fatalf("command failed: %v", err)
@@ -128,10 +144,14 @@ func doInspect(ctx context.Context, id string) error {
 }
 
 func tailCmd() *cobra.Command {
-	return &cobra.Command{
+	var f tailFilter
+	cmd := &cobra.Command{
 		Use:   "tail",
 		Short: "Tail live machine events & UI actions via NATS",
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := f.validate(); err != nil {
+				return err
+			}
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
 			c := make(chan os.Signal, 1)
@@ -140,15 +160,69 @@ func tailCmd() *cobra.Command {
 				<-c
 				cancel()
 			}()
-			if err := doTail(ctx); err != nil {
+			if err := doTail(ctx, f); err != nil {
 				logger.Errorf("tail failed: %v", err)
 				os.Exit(1)
 			}
+			return nil
 		},
 	}
+	cmd.Flags().StringVar(&f.region, "region", "", "Only show events for this region")
+	cmd.Flags().StringVar(&f.machine, "machine", "", "Only show events for this machine ID")
+	cmd.Flags().StringVar(&f.eventType, "type", "", "Only show events of this type (e.g. created, start, stop)")
+	cmd.Flags().StringVar(&f.subject, "subject", "", "Raw NATS subject to subscribe to instead of the other filters")
+	cmd.Flags().BoolVar(&f.flat, "flat", false, "Subscribe to the legacy flat machines.events subject instead of the other filters")
+	return cmd
+}
+
+// tailFilter narrows the machine event subscription using the
+// machines.events.<region>.<id>.<type> hierarchy.
+type tailFilter struct {
+	region    string
+	machine   string
+	eventType string
+	subject   string
+	flat      bool
+}
+
+// validate rejects --subject and --flat alongside the other filters, which
+// they would otherwise silently replace.
+func (f tailFilter) validate() error {
+	narrowed := f.region != "" || f.machine != "" || f.eventType != ""
+	switch {
+	case f.subject != "" && f.flat:
+		return errors.New("--subject and --flat cannot be combined")
+	case f.subject != "" && narrowed:
+		return errors.New("--subject cannot be combined with --region, --machine or --type")
+	case f.flat && narrowed:
+		return errors.New("--flat cannot be combined with --region, --machine or --type")
+	}
+	return nil
 }
 
-func doTail(ctx context.Context) error {
+func (f tailFilter) filtered() bool {
+	return f.region != "" || f.machine != "" || f.eventType != "" || f.subject != ""
+}
+
+func (f tailFilter) eventSubject() string {
+	switch {
+	case f.subject != "":
+		return f.subject
+	case f.flat:
+		return "machines.events"
+	case !f.filtered():
+		return "machines.events.>"
+	}
+	token := func(s string) string {
+		if s == "" {
+			return "*"
+		}
+		return s
+	}
+	return fmt.Sprintf("machines.events.%s.%s.%s", token(f.region), token(f.machine), token(f.eventType))
+}
+
+func doTail(ctx context.Context, f tailFilter) error {
 	nc, err := nats.Connect(natsURL)
 	if err != nil {
 		return err
@@ -157,22 +231,126 @@ func doTail(ctx context.Context) error {
 
 	//js, _ := nc.JetStream()
 	mch := make(chan *nats.Msg, 128)
-	_, err = nc.ChanSubscribe("machines.events", mch)
+	subjects := []string{f.eventSubject()}
+	if !f.filtered() {
+		subjects = append(subjects, "ui.actions")
+	}
+	for _, subj := range subjects {
+		if _, err := nc.ChanSubscribe(subj, mch); err != nil {
+			return err
+		}
+	}
+
+	logger.Infof("Listening for %s on %s", strings.Join(subjects, " and "), natsURL)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-mch:
+			fmt.Printf("[%s] %s\n", msg.Subject, string(msg.Data))
+		}
+	}
+}
+
+func hostsCmd() *cobra.Command {
+	var wait time.Duration
+	cmd := &cobra.Command{
+		Use:   "hosts",
+		Short: "Show the latest flyd.heartbeat from each flyd-sim host",
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, cancel := context.WithTimeout(context.Background(), wait)
+			defer cancel()
+			if err := doHosts(ctx); err != nil {
+				logger.Errorf("hosts failed: %v", err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().DurationVar(&wait, "wait", 12*time.Second, "How long to collect heartbeats before printing")
+	return cmd
+}
+
+// heartbeat mirrors the flyd-sim heartbeat payload.
+type heartbeat struct {
+	HostID        string         `json:"host_id"`
+	Version       string         `json:"version"`
+	UptimeSeconds int64          `json:"uptime_s"`
+	Time          int64          `json:"time"`
+	Machines      int            `json:"machines"`
+	ByRegion      map[string]int `json:"by_region"`
+	ByStatus      map[string]int `json:"by_status"`
+	Chaos         interface{}    `json:"chaos"`
+}
+
+func doHosts(ctx context.Context) error {
+	nc, err := nats.Connect(natsURL)
 	if err != nil {
 		return err
 	}
-	_, err = nc.ChanSubscribe("ui.actions", mch)
-	if err != nil {
+	defer nc.Drain()
+
+	mch := make(chan *nats.Msg, 128)
+	if _, err := nc.ChanSubscribe("flyd.heartbeat", mch); err != nil {
 		return err
 	}
 
-	logger.Infof("Listening for machines.events and ui.actions on %s", natsURL)
+	latest := map[string]heartbeat{}
+	logger.Infof("Collecting heartbeats from %s", natsURL)
+collect:
 	for {
 		select {
 		case <-ctx.Done():
-			return nil
+			break collect
 		case msg := <-mch:
-			fmt.Printf("[%s] %s\n", msg.Subject, string(msg.Data))
+			var hb heartbeat
+			if err := json.Unmarshal(msg.Data, &hb); err != nil || hb.HostID == "" {
+				continue
+			}
+			if prev, ok := latest[hb.HostID]; !ok || hb.Time >= prev.Time {
+				latest[hb.HostID] = hb
+			}
 		}
 	}
+
+	if len(latest) == 0 {
+		fmt.Println("no heartbeats received")
+		return nil
+	}
+	ids := make([]string, 0, len(latest))
+	for id := range latest {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	fmt.Printf("HOST\tVERSION\tUPTIME\tMACHINES\tREGIONS\tSTATUS\tCHAOS\tLAST SEEN\n")
+	for _, id := range ids {
+		hb := latest[id]
+		chaos, _ := json.Marshal(hb.Chaos)
+		fmt.Printf("%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			hb.HostID,
+			hb.Version,
+			(time.Duration(hb.UptimeSeconds) * time.Second).String(),
+			hb.Machines,
+			formatCounts(hb.ByRegion),
+			formatCounts(hb.ByStatus),
+			string(chaos),
+			time.Unix(hb.Time, 0).Format(time.RFC3339),
+		)
+	}
+	return nil
+}
+
+func formatCounts(m map[string]int) string {
+	if len(m) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, m[k]))
+	}
+	return strings.Join(parts, ",")
 }
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestTailFilterSubject(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		f    tailFilter
+		want string
+	}{
+		{"everything", tailFilter{}, "machines.events.>"},
+		{"region", tailFilter{region: "iad"}, "machines.events.iad.*.*"},
+		{"machine", tailFilter{machine: "m1"}, "machines.events.*.m1.*"},
+		{"type", tailFilter{eventType: "created"}, "machines.events.*.*.created"},
+		{"region and type", tailFilter{region: "iad", eventType: "stop"}, "machines.events.iad.*.stop"},
+		{"all filters", tailFilter{region: "iad", machine: "m1", eventType: "start"}, "machines.events.iad.m1.start"},
+		{"flat", tailFilter{flat: true}, "machines.events"},
+		{"subject", tailFilter{subject: "machines.events.ams.>"}, "machines.events.ams.>"},
+	} {
+		if err := tc.f.validate(); err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.name, err)
+		}
+		if got := tc.f.eventSubject(); got != tc.want {
+			t.Fatalf("%s: expected %s, got %s", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestTailFilterRejectsOverriddenFilters(t *testing.T) {
+	for name, f := range map[string]tailFilter{
+		"flat and region":     {flat: true, region: "iad"},
+		"flat and type":       {flat: true, eventType: "created"},
+		"subject and flat":    {subject: "machines.events.>", flat: true},
+		"subject and machine": {subject: "machines.events.>", machine: "m1"},
+	} {
+		if err := f.validate(); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}